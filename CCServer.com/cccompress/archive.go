@@ -0,0 +1,399 @@
+package cccompress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"CCServer.com/ccutility"
+)
+
+// A CC archive holds many entries,each one being a CC container as written
+// by CompressWithOptions,followed by a directory table and a trailer:
+//
+//	Magic [4]byte | entry data... | directory | DirOffset [8]byte | DirCount [4]byte | Magic [4]byte
+//
+// each directory record being
+//
+//	NameLen [2]byte | Name | Mode [1]byte | Offset [8]byte | Size [8]byte |
+//	OriginSize [8]byte | FileMode [4]byte | ModTime [8]byte | CRC32 [4]byte
+//
+// Integers are big endian like the CC header. The trailer is at a fixed
+// place from the end,so a single entry is read without reading the others.
+var CCArchiveMagic = [...]byte{'C', '.', 'C', 'A'}
+
+// archiveTrailerSize .
+const archiveTrailerSize = 8 + 4 + 4
+
+// archiveRecordSize is the size of a directory record without its name
+const archiveRecordSize = 2 + 1 + 8 + 8 + 8 + 4 + 8 + 4
+
+// CCArchiveEntry .
+type CCArchiveEntry struct {
+	Name       string // slash separated relative path
+	Mode       byte   // compress mode of the entry
+	Offset     int64
+	Size       int64 // stored size
+	OriginSize int64
+	FileMode   os.FileMode
+	ModTime    time.Time
+	CRC32      uint32 // of the original data
+}
+
+// CCArchiveWriter .
+type CCArchiveWriter struct {
+	w       *bufio.Writer
+	key     string
+	offset  int64
+	entries []*CCArchiveEntry
+	names   map[string]bool
+}
+
+// NewArchiveWriter writes the magic at once,key obfuscates every entry like
+// Compress does.
+func NewArchiveWriter(w io.Writer, key string) (*CCArchiveWriter, error) {
+	p := &CCArchiveWriter{w: bufio.NewWriter(w), key: key, names: map[string]bool{}}
+	if err := p.write(CCArchiveMagic[:]); err != nil {
+		return nil, fmt.Errorf("NewArchiveWriter.Write.err[%v]", err)
+	}
+	return p, nil
+}
+
+// write .
+func (p *CCArchiveWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// Add compresses src as the entry name. fi gives the mode and mtime kept in
+// the directory,nil means 0644 and now.
+func (p *CCArchiveWriter) Add(name string, src []byte, spec CodecSpec, fi os.FileInfo) error {
+	name, err := cleanEntryName(name)
+	if err != nil {
+		return err
+	}
+	if p.names[name] {
+		return fmt.Errorf("CCArchiveWriter.Add[%v].duplicate", name)
+	}
+	if len(name) > 0xFFFF {
+		return fmt.Errorf("CCArchiveWriter.Add[%v].name too long", name)
+	}
+
+	codec, err := spec.Codec()
+	if err != nil {
+		return fmt.Errorf("CCArchiveWriter.Add[%v].err[%v]", name, err)
+	}
+	dst, err := CompressWithOptions(p.key, src, &CCOptions{Mode: spec.Mode, Codec: codec})
+	if err != nil {
+		return fmt.Errorf("CCArchiveWriter.Add[%v].err[%v]", name, err)
+	}
+
+	e := &CCArchiveEntry{
+		Name:       name,
+		Mode:       spec.Mode,
+		Offset:     p.offset,
+		Size:       int64(len(dst)),
+		OriginSize: int64(len(src)),
+		FileMode:   0644,
+		ModTime:    time.Now(),
+		CRC32:      crc32.ChecksumIEEE(src),
+	}
+	if fi != nil {
+		e.FileMode = fi.Mode()
+		e.ModTime = fi.ModTime()
+	}
+	if err = p.write(dst); err != nil {
+		return fmt.Errorf("CCArchiveWriter.Add[%v].Write.err[%v]", name, err)
+	}
+	p.entries = append(p.entries, e)
+	p.names[name] = true
+	return nil
+}
+
+// AddFile adds the file at filePath as the entry name.
+func (p *CCArchiveWriter) AddFile(name string, filePath string, spec CodecSpec) error {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("CCArchiveWriter.AddFile[%v].Stat.err[%v]", filePath, err)
+	}
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return fmt.Errorf("CCArchiveWriter.AddFile[%v].ReadBinary.err[%v]", filePath, err)
+	}
+	return p.Add(name, src, spec, fi)
+}
+
+// Close writes the directory and the trailer,it doesn't close the
+// underlying writer.
+func (p *CCArchiveWriter) Close() error {
+	dirOffset := p.offset
+	buf := new(bytes.Buffer)
+	for _, e := range p.entries {
+		binary.Write(buf, binary.BigEndian, uint16(len(e.Name)))
+		buf.WriteString(e.Name)
+		buf.WriteByte(e.Mode)
+		binary.Write(buf, binary.BigEndian, e.Offset)
+		binary.Write(buf, binary.BigEndian, e.Size)
+		binary.Write(buf, binary.BigEndian, e.OriginSize)
+		binary.Write(buf, binary.BigEndian, uint32(e.FileMode))
+		binary.Write(buf, binary.BigEndian, e.ModTime.UnixNano())
+		binary.Write(buf, binary.BigEndian, e.CRC32)
+	}
+	binary.Write(buf, binary.BigEndian, dirOffset)
+	binary.Write(buf, binary.BigEndian, uint32(len(p.entries)))
+	buf.Write(CCArchiveMagic[:])
+
+	if err := p.write(buf.Bytes()); err != nil {
+		return fmt.Errorf("CCArchiveWriter.Close.Write.err[%v]", err)
+	}
+	if err := p.w.Flush(); err != nil {
+		return fmt.Errorf("CCArchiveWriter.Close.Flush.err[%v]", err)
+	}
+	return nil
+}
+
+// cleanEntryName makes name a clean slash separated relative path,refusing
+// the ones escaping the archive.
+func cleanEntryName(name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if len(name) == 0 || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive.entry name[%v].invalid", name)
+	}
+	return clean, nil
+}
+
+// CCArchiveReader reads entries on demand from an io.ReaderAt.
+type CCArchiveReader struct {
+	r       io.ReaderAt
+	key     string
+	entries []*CCArchiveEntry
+	index   map[string]*CCArchiveEntry
+	closer  io.Closer
+}
+
+// NewArchiveReader reads the directory of the size bytes archive in r.
+func NewArchiveReader(r io.ReaderAt, size int64, key string) (*CCArchiveReader, error) {
+	if size < int64(len(CCArchiveMagic))+archiveTrailerSize {
+		return nil, fmt.Errorf("NewArchiveReader.size[%v].less", size)
+	}
+	magic := make([]byte, len(CCArchiveMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, fmt.Errorf("NewArchiveReader.Read.err[%v]", err)
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	if _, err := r.ReadAt(trailer, size-archiveTrailerSize); err != nil {
+		return nil, fmt.Errorf("NewArchiveReader.Read.err[%v]", err)
+	}
+	if !bytes.Equal(magic, CCArchiveMagic[:]) || !bytes.Equal(trailer[12:], CCArchiveMagic[:]) {
+		return nil, fmt.Errorf("NewArchiveReader.magic.no match")
+	}
+
+	dirOffset := int64(binary.BigEndian.Uint64(trailer[0:8]))
+	count := int(binary.BigEndian.Uint32(trailer[8:12]))
+	dirLen := size - archiveTrailerSize - dirOffset
+	if dirOffset < int64(len(CCArchiveMagic)) || dirLen < int64(count)*archiveRecordSize {
+		return nil, fmt.Errorf("NewArchiveReader.directory[%v/%v].broken", dirOffset, dirLen)
+	}
+	dir := make([]byte, dirLen)
+	if _, err := r.ReadAt(dir, dirOffset); err != nil {
+		return nil, fmt.Errorf("NewArchiveReader.directory.Read.err[%v]", err)
+	}
+
+	p := &CCArchiveReader{r: r, key: key, index: make(map[string]*CCArchiveEntry, count)}
+	for i := 0; i < count; i++ {
+		if len(dir) < 2 {
+			return nil, fmt.Errorf("NewArchiveReader.directory[%v].broken", i)
+		}
+		n := int(binary.BigEndian.Uint16(dir))
+		if len(dir) < archiveRecordSize+n {
+			return nil, fmt.Errorf("NewArchiveReader.directory[%v].broken", i)
+		}
+		e := &CCArchiveEntry{Name: string(dir[2 : 2+n])}
+		b := dir[2+n:]
+		e.Mode = b[0]
+		e.Offset = int64(binary.BigEndian.Uint64(b[1:]))
+		e.Size = int64(binary.BigEndian.Uint64(b[9:]))
+		e.OriginSize = int64(binary.BigEndian.Uint64(b[17:]))
+		e.FileMode = os.FileMode(binary.BigEndian.Uint32(b[25:]))
+		e.ModTime = time.Unix(0, int64(binary.BigEndian.Uint64(b[29:])))
+		e.CRC32 = binary.BigEndian.Uint32(b[37:])
+		dir = dir[archiveRecordSize+n:]
+
+		// Offset+Size could overflow
+		if e.Offset < int64(len(CCArchiveMagic)) || e.Offset > dirOffset || e.Size < 0 || e.Size > dirOffset-e.Offset {
+			return nil, fmt.Errorf("NewArchiveReader.entry[%v].out of range", e.Name)
+		}
+		if _, err := cleanEntryName(e.Name); err != nil {
+			return nil, fmt.Errorf("NewArchiveReader.err[%v]", err)
+		}
+		p.entries = append(p.entries, e)
+		p.index[e.Name] = e
+	}
+	return p, nil
+}
+
+// OpenArchive opens the archive at filePath,Close closes the file.
+func OpenArchive(filePath string, key string) (*CCArchiveReader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("OpenArchive[%v].Open.err[%v]", filePath, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenArchive[%v].Stat.err[%v]", filePath, err)
+	}
+	p, err := NewArchiveReader(f, fi.Size(), key)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("OpenArchive[%v].err[%v]", filePath, err)
+	}
+	p.closer = f
+	return p, nil
+}
+
+// Close .
+func (p *CCArchiveReader) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// Entries returns the entries in the order they were added.
+func (p *CCArchiveReader) Entries() []*CCArchiveEntry {
+	return p.entries
+}
+
+// Entry returns nil when there is no entry name.
+func (p *CCArchiveReader) Entry(name string) *CCArchiveEntry {
+	if clean, err := cleanEntryName(name); err == nil {
+		name = clean
+	}
+	return p.index[name]
+}
+
+// ReadFile decompresses the entry name,checking its size and CRC32.
+func (p *CCArchiveReader) ReadFile(name string) ([]byte, error) {
+	e := p.Entry(name)
+	if e == nil {
+		return nil, fmt.Errorf("CCArchiveReader.ReadFile[%v].%w", name, os.ErrNotExist)
+	}
+	return p.ReadEntry(e)
+}
+
+// ReadEntry .
+func (p *CCArchiveReader) ReadEntry(e *CCArchiveEntry) ([]byte, error) {
+	src := make([]byte, e.Size)
+	if _, err := p.r.ReadAt(src, e.Offset); err != nil {
+		return nil, fmt.Errorf("CCArchiveReader.ReadEntry[%v].Read.err[%v]", e.Name, err)
+	}
+	_, dst, err := Decompress(p.key, src, e.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("CCArchiveReader.ReadEntry[%v].err[%v]", e.Name, err)
+	}
+	if int64(len(dst)) != e.OriginSize || crc32.ChecksumIEEE(dst) != e.CRC32 {
+		return nil, fmt.Errorf("CCArchiveReader.ReadEntry[%v].size or crc32.no match", e.Name)
+	}
+	return dst, nil
+}
+
+// PackFolder packs every matching file under folders into archivePath,with
+// the codec of opts.Spec/opts.Rules. OutDir,Journal and Incremental don't
+// apply.
+func PackFolder(folders string, archivePath string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("PackFolder[%v].opts.nil", folders)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("PackFolder[%v].err[%v]", folders, err)
+	}
+
+	filter := opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(opts.Ext)
+	}
+	var allFile []string
+	allFile, err = ccutility.GetAllFileByFilter(folders, filter, allFile)
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(allFile)
+
+	out, err := ccutility.CreateAtomic(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("PackFolder[%v].err[%v]", archivePath, err)
+	}
+	w, err := NewArchiveWriter(out.File, opts.Key)
+	if err != nil {
+		out.Abort()
+		return 0, err
+	}
+
+	absArchive, _ := filepath.Abs(archivePath)
+	for _, f := range allFile {
+		// the archive may be written inside the folder
+		if abs, _ := filepath.Abs(f); abs == absArchive {
+			continue
+		}
+		rel, e := filepath.Rel(folders, f)
+		if e != nil {
+			out.Abort()
+			return successed, fmt.Errorf("PackFolder[%v].Rel.err[%v]", f, e)
+		}
+		rel = filepath.ToSlash(rel)
+		if e = w.AddFile(rel, f, opts.specFor(rel)); e != nil {
+			out.Abort()
+			return successed, fmt.Errorf("PackFolder[%v].err[%v]", archivePath, e)
+		}
+		successed++
+	}
+
+	if err = w.Close(); err != nil {
+		out.Abort()
+		return 0, fmt.Errorf("PackFolder[%v].err[%v]", archivePath, err)
+	}
+	if err = out.Commit(""); err != nil {
+		return 0, fmt.Errorf("PackFolder[%v].err[%v]", archivePath, err)
+	}
+	return successed, nil
+}
+
+// UnpackArchive extracts every entry of archivePath under outDir,keeping the
+// file modes and mtimes.
+func UnpackArchive(archivePath string, outDir string, key string) (successed int64, err error) {
+	r, err := OpenArchive(archivePath, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	for _, e := range r.Entries() {
+		dst, e2 := r.ReadEntry(e)
+		if e2 != nil {
+			return successed, fmt.Errorf("UnpackArchive[%v].err[%v]", archivePath, e2)
+		}
+		filePath := filepath.Join(outDir, filepath.FromSlash(e.Name))
+		if e2 = os.MkdirAll(filepath.Dir(filePath), 0755); e2 != nil {
+			return successed, fmt.Errorf("UnpackArchive[%v].MkdirAll.err[%v]", filePath, e2)
+		}
+		if _, e2 = ccutility.WriteBinary(filePath, dst); e2 != nil {
+			return successed, fmt.Errorf("UnpackArchive[%v].err[%v]", filePath, e2)
+		}
+		if e2 = ccutility.ApplyFileInfo(filePath, e.FileMode.Perm(), e.ModTime); e2 != nil {
+			return successed, fmt.Errorf("UnpackArchive[%v].err[%v]", filePath, e2)
+		}
+		successed++
+	}
+	return successed, nil
+}
@@ -0,0 +1,201 @@
+package cccompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// archiveSample packs entries,name -> data,with key.
+func archiveSample(t *testing.T, key string, entries map[string][]byte, names []string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	w, err := NewArchiveWriter(buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err = w.Add(name, entries[name], CodecSpec{Mode: GZip}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	entries := map[string][]byte{
+		"a.txt":         []byte("hello archive"),
+		"sub/b.json":    bytes.Repeat([]byte(`{"k":"v"}`), 100),
+		"sub/deep/c.md": {},
+	}
+	names := []string{"a.txt", "sub/b.json", "sub/deep/c.md"}
+
+	for _, key := range []string{"", "xxx.yyy"} {
+		src := archiveSample(t, key, entries, names)
+		r, err := NewArchiveReader(bytes.NewReader(src), int64(len(src)), key)
+		if err != nil {
+			t.Fatalf("key[%v].NewArchiveReader.err[%v]", key, err)
+		}
+		if len(r.Entries()) != len(names) {
+			t.Fatalf("key[%v].entries[%v/%v]", key, len(r.Entries()), len(names))
+		}
+		for i, e := range r.Entries() {
+			if e.Name != names[i] {
+				t.Errorf("key[%v].entry[%v].name[%v/%v]", key, i, e.Name, names[i])
+			}
+			got, err := r.ReadFile(e.Name)
+			if err != nil {
+				t.Fatalf("key[%v].ReadFile[%v].err[%v]", key, e.Name, err)
+			}
+			if !bytes.Equal(got, entries[e.Name]) {
+				t.Errorf("key[%v].ReadFile[%v].no match", key, e.Name)
+			}
+		}
+		if _, err = r.ReadFile("missing"); err == nil {
+			t.Errorf("key[%v].ReadFile[missing].err.nil", key)
+		}
+	}
+}
+
+func TestPackFolderUnpackArchive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	files := map[string]string{"a.txt": "hello", "sub/b.txt": "world"}
+	for name, data := range files {
+		filePath := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(data), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archivePath := filepath.Join(dir, "a.cca")
+	opts := NewFolderOptions("", "xxx.yyy", Zstd, false, 2)
+	if n, err := PackFolder(src, archivePath, opts); err != nil || n != int64(len(files)) {
+		t.Fatalf("PackFolder[%v].err[%v]", n, err)
+	}
+	out := filepath.Join(dir, "out")
+	if n, err := UnpackArchive(archivePath, out, "xxx.yyy"); err != nil || n != int64(len(files)) {
+		t.Fatalf("UnpackArchive[%v].err[%v]", n, err)
+	}
+	for name, data := range files {
+		filePath := filepath.Join(out, filepath.FromSlash(name))
+		got, err := os.ReadFile(filePath)
+		if err != nil || string(got) != data {
+			t.Errorf("UnpackArchive[%v].got[%q].err[%v]", name, got, err)
+		}
+		if fi, err := os.Stat(filePath); err == nil && fi.Mode().Perm() != 0640 {
+			t.Errorf("UnpackArchive[%v].mode[%v]", name, fi.Mode())
+		}
+	}
+}
+
+func TestArchiveCorrupted(t *testing.T) {
+	entries := map[string][]byte{"a.txt": []byte("hello archive"), "b.txt": []byte("second entry")}
+	src := archiveSample(t, "xxx.yyy", entries, []string{"a.txt", "b.txt"})
+	dirOffset := int64(binary.BigEndian.Uint64(src[len(src)-archiveTrailerSize:]))
+
+	// the Size of the first record,after its name
+	sizeAt := int(dirOffset) + 2 + len("a.txt") + 1 + 8
+	hugeSize := append([]byte(nil), src...)
+	binary.BigEndian.PutUint64(hugeSize[sizeAt:], 1<<63-1)
+
+	badCount := append([]byte(nil), src...)
+	binary.BigEndian.PutUint32(badCount[len(src)-8:], 1000)
+
+	badOffset := append([]byte(nil), src...)
+	binary.BigEndian.PutUint64(badOffset[len(src)-archiveTrailerSize:], uint64(len(src)))
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"magic only", src[:len(CCArchiveMagic)]},
+		{"truncated trailer", src[:len(src)-4]},
+		{"truncated directory", append(append([]byte(nil), src[:dirOffset+10]...), src[len(src)-archiveTrailerSize:]...)},
+		{"no directory", append(append([]byte(nil), src[:dirOffset]...), src[len(src)-archiveTrailerSize:]...)},
+		{"huge entry size", hugeSize},
+		{"bad count", badCount},
+		{"bad directory offset", badOffset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewArchiveReader(bytes.NewReader(tt.src), int64(len(tt.src)), "xxx.yyy"); err == nil {
+				t.Fatalf("NewArchiveReader.err.nil")
+			}
+		})
+	}
+
+	// a damaged entry is caught when read
+	damaged := append([]byte(nil), src...)
+	damaged[len(CCArchiveMagic)+40] ^= 0xFF
+	r, err := NewArchiveReader(bytes.NewReader(damaged), int64(len(damaged)), "xxx.yyy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadFile("a.txt"); err == nil {
+		t.Errorf("ReadFile[a.txt].damaged.err.nil")
+	}
+}
+
+func TestUnpackArchiveFileMode(t *testing.T) {
+	meta := fstest.MapFS{
+		"plain.sh":  {Mode: 0750},
+		"setuid.sh": {Mode: os.ModeSetuid | os.ModeSetgid | os.ModeSticky | 0755},
+	}
+	tests := []struct {
+		name string
+		want os.FileMode
+	}{
+		{"plain.sh", 0750},
+		{"setuid.sh", 0755},
+	}
+
+	dir := t.TempDir()
+	buf := new(bytes.Buffer)
+	w, err := NewArchiveWriter(buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		fi, err := fs.Stat(meta, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Add(tt.name, []byte("#!/bin/sh"), CodecSpec{Mode: GZip}, fi); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(dir, "a.cca")
+	if err = os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if _, err = UnpackArchive(archivePath, out, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fi, err := os.Stat(filepath.Join(out, tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode() != tt.want {
+				t.Errorf("UnpackArchive.mode[%v/%v]", fi.Mode(), tt.want)
+			}
+		})
+	}
+}
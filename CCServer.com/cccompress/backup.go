@@ -0,0 +1,157 @@
+package cccompress
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// BackupExt is appended to the files kept by CompressFile/DecompressFile
+// when they don't overwrite.
+const BackupExt = ".bak"
+
+// VerifyBackup checks that bakPath can safely replace filePath: either
+// filePath decodes to bakPath (a compress backup) or bakPath decodes to
+// filePath (a decompress backup). key and compressMode are the ones the
+// folder was processed with. Without filePath the backup only has to be
+// intact.
+func VerifyBackup(filePath string, key string, compressMode int) error {
+	bakPath := filePath + BackupExt
+	bak, err := ccutility.ReadBinary(bakPath)
+	if err != nil {
+		return fmt.Errorf("VerifyBackup[%v].err[%v]", bakPath, err)
+	}
+
+	if !fileExists(filePath) {
+		if bytes.HasPrefix(bak, CCFormat[:]) {
+			if _, err = getHeader(bak); err != nil {
+				return fmt.Errorf("VerifyBackup[%v].broken header.err[%v]", bakPath, err)
+			}
+		}
+		return nil
+	}
+
+	cur, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return fmt.Errorf("VerifyBackup[%v].err[%v]", filePath, err)
+	}
+
+	if decodesTo(cur, bak, key, compressMode) || decodesTo(bak, cur, key, compressMode) {
+		return nil
+	}
+	return fmt.Errorf("VerifyBackup[%v].no match with[%v]", bakPath, filePath)
+}
+
+// decodesTo .
+func decodesTo(src []byte, want []byte, key string, compressMode int) bool {
+	// Decompress works in place
+	buf := make([]byte, len(src))
+	copy(buf, src)
+	_, dst, err := Decompress(key, buf, byte(compressMode))
+	return err == nil && bytes.Equal(dst, want)
+}
+
+// fileExists .
+func fileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
+
+// RestoreBackup puts the backup of filePath back after verifying it.
+func RestoreBackup(filePath string, key string, compressMode int) error {
+	if err := VerifyBackup(filePath, key, compressMode); err != nil {
+		return err
+	}
+	if err := os.Rename(filePath+BackupExt, filePath); err != nil {
+		return fmt.Errorf("RestoreBackup[%v].Rename.err[%v]", filePath, err)
+	}
+	return nil
+}
+
+// PruneBackup removes the backup of filePath if filePath still exists.
+func PruneBackup(filePath string) error {
+	if !fileExists(filePath) {
+		return fmt.Errorf("PruneBackup[%v].file missing,keep the backup", filePath)
+	}
+	if err := os.Remove(filePath + BackupExt); err != nil {
+		return fmt.Errorf("PruneBackup[%v].Remove.err[%v]", filePath, err)
+	}
+	return nil
+}
+
+// RestoreBackups puts every verified backup under folders back in place of
+// its file. Backups that don't verify are left alone and reported.
+func RestoreBackups(folders string, opts *CCFolderOptions) (successed int64, err error) {
+	return processBackups("RestoreBackups", folders, opts, func(filePath string) error {
+		spec := opts.spec()
+		if rel, err := filepath.Rel(folders, filePath); err == nil {
+			spec = opts.specFor(filepath.ToSlash(rel))
+		}
+		return RestoreBackup(filePath, opts.Key, int(spec.Mode))
+	})
+}
+
+// PruneBackups removes every backup under folders whose file still exists.
+func PruneBackups(folders string, opts *CCFolderOptions) (successed int64, err error) {
+	return processBackups("PruneBackups", folders, opts, PruneBackup)
+}
+
+// processBackups runs fn over the path (without BackupExt) of every backup
+// under folders. opts.Filter narrows the backups by the path of their file:
+// its extensions and include/exclude patterns see it without BackupExt.
+func processBackups(name string, folders string, opts *CCFolderOptions, fn func(filePath string) error) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("%v[%v].opts.nil", name, folders)
+	}
+
+	// backups are found by their suffix,excluded folders aren't entered,
+	// the names are matched below
+	filter := ccutility.NewFileFilter(BackupExt)
+	if opts.Filter != nil {
+		if err = opts.Filter.Validate(); err != nil {
+			return 0, err
+		}
+		filter.Exclude = opts.Filter.Exclude
+		filter.MinSize = opts.Filter.MinSize
+		filter.MaxSize = opts.Filter.MaxSize
+		filter.IgnoreFile = opts.Filter.IgnoreFile
+	}
+
+	var found []string
+	found, err = ccutility.GetAllFileByFilter(folders, filter, found)
+	if err != nil {
+		return 0, err
+	}
+	var allFile []string
+	for _, bakPath := range found {
+		if opts.Filter != nil {
+			rel, e := filepath.Rel(folders, strings.TrimSuffix(bakPath, BackupExt))
+			if e != nil || !opts.Filter.MatchName(filepath.ToSlash(rel)) {
+				continue
+			}
+		}
+		allFile = append(allFile, bakPath)
+	}
+
+	var lock = new(sync.RWMutex)
+	runWorkers(len(allFile), opts.WorkerNum, func(idx int) {
+		filePath := strings.TrimSuffix(allFile[idx], BackupExt)
+		e := fn(filePath)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if e != nil {
+			log.Printf("%v[%v].err[%v]", name, filePath, e)
+			err = e
+			return
+		}
+		successed++
+	})
+	return successed, err
+}
@@ -0,0 +1,70 @@
+package cccompress
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"CCServer.com/ccutility"
+)
+
+func TestPruneBackupsFilter(t *testing.T) {
+	files := []string{"a.txt", "b.png", "sub/c.txt", "sub/d.json", "skip/e.txt"}
+
+	tests := []struct {
+		name   string
+		filter *ccutility.FileFilter
+		want   []string // files whose backup is removed
+	}{
+		{"no filter", nil, files},
+		{"every file", &ccutility.FileFilter{}, files},
+		{"ext", &ccutility.FileFilter{Exts: []string{"txt"}}, []string{"a.txt", "sub/c.txt", "skip/e.txt"}},
+		{"exts", &ccutility.FileFilter{Exts: []string{".png", ".json"}}, []string{"b.png", "sub/d.json"}},
+		{"include", &ccutility.FileFilter{Include: []string{"sub/**"}}, []string{"sub/c.txt", "sub/d.json"}},
+		{"include by ext", &ccutility.FileFilter{Include: []string{"**/*.txt"}}, []string{"a.txt", "sub/c.txt", "skip/e.txt"}},
+		{"exclude folder", &ccutility.FileFilter{Exclude: []string{"skip"}}, []string{"a.txt", "b.png", "sub/c.txt", "sub/d.json"}},
+		{"exclude file", &ccutility.FileFilter{Exclude: []string{"**/*.txt"}}, []string{"b.png", "sub/d.json"}},
+		{"ext and include", &ccutility.FileFilter{Exts: []string{"txt"}, Include: []string{"sub/*"}}, []string{"sub/c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range files {
+				filePath := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				for _, p := range []string{filePath, filePath + BackupExt} {
+					if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			opts := NewFolderOptions("", "", GZip, false, 2)
+			opts.Filter = tt.filter
+			n, err := PruneBackups(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(tt.want)) {
+				t.Errorf("PruneBackups[%v/%v]", n, len(tt.want))
+			}
+
+			var pruned []string
+			for _, name := range files {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)+BackupExt)); os.IsNotExist(err) {
+					pruned = append(pruned, name)
+				}
+			}
+			want := append([]string(nil), tt.want...)
+			sort.Strings(pruned)
+			sort.Strings(want)
+			if strings.Join(pruned, ",") != strings.Join(want, ",") {
+				t.Errorf("PruneBackups.pruned[%v/%v]", pruned, want)
+			}
+		})
+	}
+}
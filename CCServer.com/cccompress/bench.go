@@ -0,0 +1,171 @@
+package cccompress
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CCBenchResult is what BenchCodec measured for a codec spec.
+type CCBenchResult struct {
+	Spec       CodecSpec
+	Files      int
+	Origin     int64
+	Compressed int64
+	Compress   time.Duration
+	Decompress time.Duration
+	PeakMemory uint64 // heap above the one before the run,sampled
+}
+
+// Ratio is Compressed/Origin.
+func (p *CCBenchResult) Ratio() float64 {
+	if p.Origin == 0 {
+		return 0
+	}
+	return float64(p.Compressed) / float64(p.Origin)
+}
+
+// CompressMBs is the compress throughput in MB/s of original data.
+func (p *CCBenchResult) CompressMBs() float64 {
+	return mbs(p.Origin, p.Compress)
+}
+
+// DecompressMBs is the decompress throughput in MB/s of original data.
+func (p *CCBenchResult) DecompressMBs() float64 {
+	return mbs(p.Origin, p.Decompress)
+}
+
+// mbs .
+func mbs(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / (1 << 20) / d.Seconds()
+}
+
+// BenchSpecs returns every registered codec,at its fast,default and best
+// level when it has levels.
+func BenchSpecs() []CodecSpec {
+	var ret []CodecSpec
+	for _, info := range Codecs() {
+		if info.New == nil || len(info.Levels) == 0 {
+			ret = append(ret, CodecSpec{Mode: info.Mode})
+			continue
+		}
+		seen := map[int]bool{}
+		var levels []int
+		for _, name := range []string{"fast", "default", "best"} {
+			if l, ok := info.Levels[name]; ok && !seen[l] {
+				seen[l] = true
+				levels = append(levels, l)
+			}
+		}
+		sort.Ints(levels)
+		for _, l := range levels {
+			ret = append(ret, CodecSpec{Mode: info.Mode, Level: l, HasLevel: true})
+		}
+	}
+	return ret
+}
+
+// BenchCodec compresses and decompresses every sample with spec,checking
+// the round trip. Samples are run one after the other.
+func BenchCodec(spec CodecSpec, samples [][]byte) (*CCBenchResult, error) {
+	codec, err := spec.Codec()
+	if err != nil {
+		return nil, fmt.Errorf("BenchCodec[%v].err[%v]", spec.String(), err)
+	}
+	ret := &CCBenchResult{Spec: spec, Files: len(samples)}
+
+	sampler := startPeakSampler()
+	defer sampler.stop()
+
+	for i, src := range samples {
+		s := time.Now()
+		dst, err := codec.Compress(src)
+		ret.Compress += time.Since(s)
+		if err != nil {
+			return nil, fmt.Errorf("BenchCodec[%v].sample[%v].Compress.err[%v]", spec.String(), i, err)
+		}
+
+		s = time.Now()
+		back, err := codec.Decompress(dst)
+		ret.Decompress += time.Since(s)
+		if err != nil {
+			return nil, fmt.Errorf("BenchCodec[%v].sample[%v].Decompress.err[%v]", spec.String(), i, err)
+		}
+		if !bytes.Equal(back, src) {
+			return nil, fmt.Errorf("BenchCodec[%v].sample[%v].round trip.no match", spec.String(), i)
+		}
+
+		ret.Origin += int64(len(src))
+		ret.Compressed += int64(len(dst))
+	}
+
+	ret.PeakMemory = sampler.stop()
+	return ret, nil
+}
+
+// heapMetric is cheap to read,unlike runtime.ReadMemStats
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// peakSampler samples the heap every millisecond until stopped.
+type peakSampler struct {
+	base uint64
+	peak uint64
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// startPeakSampler .
+func startPeakSampler() *peakSampler {
+	runtime.GC()
+	p := &peakSampler{done: make(chan struct{})}
+	p.base = heapBytes()
+	p.peak = p.base
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-t.C:
+				if n := heapBytes(); n > p.peak {
+					p.peak = n
+				}
+			}
+		}
+	}()
+	return p
+}
+
+// stop returns the peak above the base,it may be called more than once.
+func (p *peakSampler) stop() uint64 {
+	p.once.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+		if n := heapBytes(); n > p.peak {
+			p.peak = n
+		}
+	})
+	return p.peak - p.base
+}
+
+// heapBytes .
+func heapBytes() uint64 {
+	s := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(s)
+	if s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s[0].Value.Uint64()
+}
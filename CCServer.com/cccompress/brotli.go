@@ -0,0 +1,71 @@
+package cccompress
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CCBrotli .
+type CCBrotli struct {
+	CompressionLevel int
+}
+
+// Compress .
+func (p *CCBrotli) Compress(in []byte) ([]byte, error) {
+	var (
+		buffer bytes.Buffer
+		out    []byte
+		err    error
+	)
+	writer := brotli.NewWriterLevel(&buffer, p.CompressionLevel)
+	_, err = writer.Write(in)
+	if err != nil {
+		return out, err
+	}
+	if err = writer.Close(); err != nil {
+		return out, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decompress .
+func (p *CCBrotli) Decompress(in []byte) ([]byte, error) {
+	return ioutil.ReadAll(brotli.NewReader(bytes.NewReader(in)))
+}
+
+// NewWriter .
+func (p *CCBrotli) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return brotli.NewWriterLevel(w, p.CompressionLevel), nil
+}
+
+// NewReader .
+func (p *CCBrotli) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+// NewBrotli .
+func NewBrotli() *CCBrotli {
+	return &CCBrotli{
+		CompressionLevel: brotli.DefaultCompression,
+	}
+}
+
+// DefaultBrotli .
+var DefaultBrotli = NewBrotli()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:       Brotli,
+		Name:       "br",
+		Aliases:    []string{"brotli"},
+		HTTPCoding: "br",
+		Default:    func() Codec { return DefaultBrotli },
+		New:        func(level int) Codec { return &CCBrotli{CompressionLevel: level} },
+		MinLevel:   brotli.BestSpeed,
+		MaxLevel:   brotli.BestCompression,
+		Levels:     levelNames(brotli.BestSpeed, brotli.DefaultCompression, brotli.BestCompression),
+	})
+}
@@ -0,0 +1,440 @@
+package cccompress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"CCServer.com/ccutility"
+)
+
+// bundle formats,a bundle is a .zip or .tar(.gz) made by other tools
+const (
+	BundleZip   = "zip"
+	BundleTar   = "tar"
+	BundleTarGz = "tar.gz"
+)
+
+// BundleFormat is the format of a bundle path by its extension,
+// .zip,.tar,.tar.gz or .tgz.
+func BundleFormat(filePath string) (string, error) {
+	name := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return BundleZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return BundleTarGz, nil
+	case strings.HasSuffix(name, ".tar"):
+		return BundleTar, nil
+	}
+	return "", fmt.Errorf("BundleFormat[%v].unknown,use .zip/.tar/.tar.gz/.tgz", filePath)
+}
+
+// sniffBundle is the format of an existing bundle by its first bytes,so a
+// misnamed one still reads.
+func sniffBundle(f *os.File) (string, error) {
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	switch {
+	case n >= 4 && bytes.Equal(head, []byte("PK\x03\x04")), n >= 4 && bytes.Equal(head, []byte("PK\x05\x06")):
+		return BundleZip, nil
+	case n >= 2 && head[0] == 0x1F && head[1] == 0x8B:
+		return BundleTarGz, nil
+	}
+	return BundleTar, nil
+}
+
+// bundleFileInfo only keeps the permission bits of an entry,the setuid,
+// setgid and sticky bits of a bundle from elsewhere aren't carried over.
+type bundleFileInfo struct {
+	os.FileInfo
+}
+
+// Mode .
+func (p bundleFileInfo) Mode() os.FileMode {
+	return p.FileInfo.Mode().Perm()
+}
+
+// walkBundle calls fn for every regular file of the bundle at filePath,with
+// its cleaned name and its permission bits only. Folders are skipped,links
+// and devices are logged and skipped.
+func walkBundle(filePath string, fn func(name string, fi os.FileInfo, r io.Reader) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("walkBundle[%v].err[%v]", filePath, err)
+	}
+	defer f.Close()
+
+	format, err := sniffBundle(f)
+	if err != nil {
+		return fmt.Errorf("walkBundle[%v].err[%v]", filePath, err)
+	}
+
+	visit := func(name string, fi os.FileInfo, open func() (io.ReadCloser, error)) error {
+		if fi.IsDir() {
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			log.Printf("walkBundle[%v].entry[%v].mode[%v].skipped", filePath, name, fi.Mode())
+			return nil
+		}
+		clean, err := cleanEntryName(name)
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].err[%v]", filePath, err)
+		}
+		r, err := open()
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].entry[%v].err[%v]", filePath, name, err)
+		}
+		defer r.Close()
+		return fn(clean, bundleFileInfo{fi}, r)
+	}
+
+	if format == BundleZip {
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].Stat.err[%v]", filePath, err)
+		}
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].zip.err[%v]", filePath, err)
+		}
+		for _, zf := range zr.File {
+			if err = visit(zf.Name, zf.FileInfo(), zf.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bufio.NewReader(f)
+	if format == BundleTarGz {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].gzip.err[%v]", filePath, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("walkBundle[%v].tar.err[%v]", filePath, err)
+		}
+		err = visit(hdr.Name, hdr.FileInfo(), func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// bundleWriter writes the files of a bundle.
+type bundleWriter struct {
+	zw *zip.Writer
+	tw *tar.Writer
+	gw *gzip.Writer
+}
+
+// newBundleWriter writes a bundle of the given format to w.
+func newBundleWriter(w io.Writer, format string) *bundleWriter {
+	switch format {
+	case BundleZip:
+		return &bundleWriter{zw: zip.NewWriter(w)}
+	case BundleTarGz:
+		gw := gzip.NewWriter(w)
+		return &bundleWriter{tw: tar.NewWriter(gw), gw: gw}
+	}
+	return &bundleWriter{tw: tar.NewWriter(w)}
+}
+
+// Add .
+func (p *bundleWriter) Add(name string, data []byte, mode os.FileMode, modTime time.Time) error {
+	if p.zw != nil {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+		hdr.SetMode(mode)
+		w, err := p.zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	if err := p.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := p.tw.Write(data)
+	return err
+}
+
+// Close ends the bundle,not the underlying writer.
+func (p *bundleWriter) Close() error {
+	if p.zw != nil {
+		return p.zw.Close()
+	}
+	if err := p.tw.Close(); err != nil {
+		return err
+	}
+	if p.gw != nil {
+		return p.gw.Close()
+	}
+	return nil
+}
+
+// matchBundle applies opts.Filter to a bundle file,nil matches every file.
+func matchBundle(opts *CCFolderOptions, name string, size int64) bool {
+	if opts.Filter == nil {
+		return true
+	}
+	return opts.Filter.MatchName(name) && opts.Filter.MatchSize(size)
+}
+
+// ImportBundle compresses every file of the zip/tar(.gz) at bundlePath into
+// outDir under its path in the bundle,with the codec of opts.Spec/opts.Rules
+// and opts.Key. Modes and mtimes are kept in the CC header and on the files.
+// Only opts.Filter applies,a nil one takes every file.
+func ImportBundle(bundlePath string, outDir string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("ImportBundle[%v].opts.nil", bundlePath)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("ImportBundle[%v].err[%v]", bundlePath, err)
+	}
+
+	err = walkBundle(bundlePath, func(name string, fi os.FileInfo, r io.Reader) error {
+		if !matchBundle(opts, name, fi.Size()) {
+			return nil
+		}
+		src, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("ImportBundle[%v].entry[%v].err[%v]", bundlePath, name, err)
+		}
+		spec := opts.specFor(name)
+		codec, err := spec.Codec()
+		if err != nil {
+			return fmt.Errorf("ImportBundle[%v].entry[%v].err[%v]", bundlePath, name, err)
+		}
+		dst, err := CompressWithOptions(opts.Key, src, &CCOptions{Mode: spec.Mode, Codec: codec, Ext: FileInfoExt(fi), Checksum: true})
+		if err != nil {
+			return fmt.Errorf("ImportBundle[%v].entry[%v].err[%v]", bundlePath, name, err)
+		}
+
+		filePath := filepath.Join(outDir, filepath.FromSlash(name))
+		if _, err = ccutility.WriteBinary(filePath, dst); err != nil {
+			return fmt.Errorf("ImportBundle[%v].err[%v]", filePath, err)
+		}
+		if err = ccutility.ApplyFileInfo(filePath, fi.Mode(), fi.ModTime()); err != nil {
+			return fmt.Errorf("ImportBundle[%v].err[%v]", filePath, err)
+		}
+		successed++
+		return nil
+	})
+	return successed, err
+}
+
+// ImportBundleToArchive packs every file of the zip/tar(.gz) at bundlePath
+// into the CC archive archivePath,like ImportBundle.
+func ImportBundleToArchive(bundlePath string, archivePath string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("ImportBundleToArchive[%v].opts.nil", bundlePath)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("ImportBundleToArchive[%v].err[%v]", bundlePath, err)
+	}
+
+	out, err := ccutility.CreateAtomic(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("ImportBundleToArchive[%v].err[%v]", archivePath, err)
+	}
+	w, err := NewArchiveWriter(out.File, opts.Key)
+	if err != nil {
+		out.Abort()
+		return 0, err
+	}
+
+	err = walkBundle(bundlePath, func(name string, fi os.FileInfo, r io.Reader) error {
+		if !matchBundle(opts, name, fi.Size()) {
+			return nil
+		}
+		src, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("ImportBundleToArchive[%v].entry[%v].err[%v]", bundlePath, name, err)
+		}
+		if err = w.Add(name, src, opts.specFor(name), fi); err != nil {
+			return fmt.Errorf("ImportBundleToArchive[%v].err[%v]", archivePath, err)
+		}
+		successed++
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		out.Abort()
+		return 0, err
+	}
+	if err = out.Commit(""); err != nil {
+		return 0, fmt.Errorf("ImportBundleToArchive[%v].err[%v]", archivePath, err)
+	}
+	return successed, nil
+}
+
+// ExportFolder decodes every matching file under folders into the bundle
+// bundlePath,whose format comes from its extension. A file is decoded with
+// opts.Key and the mode of opts.Spec/opts.Rules,its mode and mtime come from
+// the CC header when it kept them,else from the file.
+func ExportFolder(folders string, bundlePath string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("ExportFolder[%v].opts.nil", folders)
+	}
+	format, err := BundleFormat(bundlePath)
+	if err != nil {
+		return 0, err
+	}
+
+	filter := opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(opts.Ext)
+	}
+	var allFile []string
+	allFile, err = ccutility.GetAllFileByFilter(folders, filter, allFile)
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(allFile)
+
+	out, err := ccutility.CreateAtomic(bundlePath)
+	if err != nil {
+		return 0, fmt.Errorf("ExportFolder[%v].err[%v]", bundlePath, err)
+	}
+	w := newBundleWriter(out.File, format)
+
+	absBundle, _ := filepath.Abs(bundlePath)
+	for _, f := range allFile {
+		// the bundle may be written inside the folder
+		if abs, _ := filepath.Abs(f); abs == absBundle {
+			continue
+		}
+		if err = exportFile(w, folders, f, opts); err != nil {
+			out.Abort()
+			return successed, fmt.Errorf("ExportFolder[%v].err[%v]", bundlePath, err)
+		}
+		successed++
+	}
+
+	if err = w.Close(); err != nil {
+		out.Abort()
+		return 0, fmt.Errorf("ExportFolder[%v].err[%v]", bundlePath, err)
+	}
+	if err = out.Commit(""); err != nil {
+		return 0, fmt.Errorf("ExportFolder[%v].err[%v]", bundlePath, err)
+	}
+	return successed, nil
+}
+
+// exportFile .
+func exportFile(w *bundleWriter, folders string, filePath string, opts *CCFolderOptions) error {
+	rel, err := filepath.Rel(folders, filePath)
+	if err != nil {
+		return fmt.Errorf("exportFile[%v].Rel.err[%v]", filePath, err)
+	}
+	rel = filepath.ToSlash(rel)
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("exportFile[%v].Stat.err[%v]", filePath, err)
+	}
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return fmt.Errorf("exportFile[%v].ReadBinary.err[%v]", filePath, err)
+	}
+	header, dst, err := Decompress(opts.Key, src, opts.specFor(rel).Mode)
+	if err != nil {
+		return fmt.Errorf("exportFile[%v].Decompress.err[%v]", filePath, err)
+	}
+
+	mode, modTime := fi.Mode(), fi.ModTime()
+	if header != nil {
+		if m, ok := header.FileMode(); ok {
+			mode = m
+		}
+		if t, ok := header.ModTime(); ok {
+			modTime = t
+		}
+	}
+	if err = w.Add(rel, dst, mode, modTime); err != nil {
+		return fmt.Errorf("exportFile[%v].err[%v]", filePath, err)
+	}
+	return nil
+}
+
+// ExportArchive decodes every entry of the CC archive archivePath into the
+// bundle bundlePath,keeping the entry modes and mtimes.
+func ExportArchive(archivePath string, bundlePath string, key string) (successed int64, err error) {
+	format, err := BundleFormat(bundlePath)
+	if err != nil {
+		return 0, err
+	}
+	r, err := OpenArchive(archivePath, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	out, err := ccutility.CreateAtomic(bundlePath)
+	if err != nil {
+		return 0, fmt.Errorf("ExportArchive[%v].err[%v]", bundlePath, err)
+	}
+	w := newBundleWriter(out.File, format)
+
+	for _, e := range r.Entries() {
+		dst, e2 := r.ReadEntry(e)
+		if e2 == nil {
+			e2 = w.Add(e.Name, dst, e.FileMode, e.ModTime)
+		}
+		if e2 != nil {
+			out.Abort()
+			return successed, fmt.Errorf("ExportArchive[%v].entry[%v].err[%v]", archivePath, e.Name, e2)
+		}
+		successed++
+	}
+
+	if err = w.Close(); err != nil {
+		out.Abort()
+		return 0, fmt.Errorf("ExportArchive[%v].err[%v]", bundlePath, err)
+	}
+	if err = out.Commit(""); err != nil {
+		return 0, fmt.Errorf("ExportArchive[%v].err[%v]", bundlePath, err)
+	}
+	return successed, nil
+}
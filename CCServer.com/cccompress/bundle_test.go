@@ -0,0 +1,182 @@
+package cccompress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// bundleEntry is a file written into a test bundle.
+type bundleEntry struct {
+	name string
+	data string
+	mode os.FileMode
+	link bool // a symbolic link,skipped on import
+}
+
+// bundleSample writes entries as a bundle of format at bundlePath.
+func bundleSample(t *testing.T, bundlePath string, format string, entries []bundleEntry) {
+	t.Helper()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	buf := new(bytes.Buffer)
+	if format == BundleZip {
+		zw := zip.NewWriter(buf)
+		for _, e := range entries {
+			fh := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: modTime}
+			fh.SetMode(e.mode)
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(e.data))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		var gw *gzip.Writer
+		tw := tar.NewWriter(buf)
+		if format == BundleTarGz {
+			gw = gzip.NewWriter(buf)
+			tw = tar.NewWriter(gw)
+		}
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Size: int64(len(e.data)), ModTime: modTime, Typeflag: tar.TypeReg}
+			if e.mode&os.ModeSetuid != 0 {
+				hdr.Mode |= 04000
+			}
+			if e.link {
+				hdr.Typeflag = tar.TypeSymlink
+				hdr.Linkname = "/etc/passwd"
+				hdr.Size = 0
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if !e.link {
+				tw.Write([]byte(e.data))
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gw != nil {
+			if err := gw.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(bundlePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportExportBundle(t *testing.T) {
+	entries := []bundleEntry{
+		{name: "a.txt", data: "hello bundle", mode: 0640},
+		{name: "sub/./b.json", data: `{"k":"v"}`, mode: 0644},
+		{name: "bin/run.sh", data: "#!/bin/sh", mode: os.ModeSetuid | 0755},
+	}
+	want := map[string]struct {
+		data string
+		mode os.FileMode
+	}{
+		"a.txt":      {"hello bundle", 0640},
+		"sub/b.json": {`{"k":"v"}`, 0644},
+		"bin/run.sh": {"#!/bin/sh", 0755}, // setuid isn't carried over
+	}
+
+	tests := []struct {
+		format string
+		ext    string
+	}{
+		{BundleZip, ".zip"},
+		{BundleTar, ".tar"},
+		{BundleTarGz, ".tgz"},
+		{BundleTarGz, ".bin"}, // misnamed,found by its first bytes
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+tt.ext, func(t *testing.T) {
+			dir := t.TempDir()
+			bundlePath := filepath.Join(dir, "in"+tt.ext)
+			all := entries
+			if tt.format != BundleZip {
+				all = append(all, bundleEntry{name: "link", mode: os.ModeSymlink | 0777, link: true})
+			}
+			bundleSample(t, bundlePath, tt.format, all)
+
+			out := filepath.Join(dir, "out")
+			opts := NewFolderOptions("", "xxx.yyy", Zstd, false, 1)
+			if n, err := ImportBundle(bundlePath, out, opts); err != nil || n != int64(len(want)) {
+				t.Fatalf("ImportBundle[%v].err[%v]", n, err)
+			}
+			if _, err := os.Lstat(filepath.Join(out, "link")); !os.IsNotExist(err) {
+				t.Errorf("ImportBundle.link imported[%v]", err)
+			}
+			for name, w := range want {
+				filePath := filepath.Join(out, filepath.FromSlash(name))
+				fi, err := os.Stat(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode() != w.mode {
+					t.Errorf("ImportBundle[%v].mode[%v/%v]", name, fi.Mode(), w.mode)
+				}
+				src, err := os.ReadFile(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				header, got, err := Decompress("xxx.yyy", src, Zstd)
+				if err != nil || string(got) != w.data {
+					t.Errorf("ImportBundle[%v].got[%q].err[%v]", name, got, err)
+				}
+				if m, ok := header.FileMode(); !ok || m != w.mode {
+					t.Errorf("ImportBundle[%v].header mode[%v/%v]", name, m, w.mode)
+				}
+			}
+
+			// and back out to a bundle,then in again
+			exported := filepath.Join(dir, "exported.zip")
+			if n, err := ExportFolder(out, exported, opts); err != nil || n != int64(len(want)) {
+				t.Fatalf("ExportFolder[%v].err[%v]", n, err)
+			}
+			again := filepath.Join(dir, "again")
+			if n, err := ImportBundle(exported, again, NewFolderOptions("", "", GZip, false, 1)); err != nil || n != int64(len(want)) {
+				t.Fatalf("ImportBundle.exported[%v].err[%v]", n, err)
+			}
+			for name, w := range want {
+				src, err := os.ReadFile(filepath.Join(again, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, got, err := Decompress("", src, GZip); err != nil || string(got) != w.data {
+					t.Errorf("ExportFolder[%v].got[%q].err[%v]", name, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestImportBundleBadName(t *testing.T) {
+	tests := []string{"../evil.txt", "sub/../../evil.txt", "/etc/evil.txt"}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			bundlePath := filepath.Join(dir, "in.tar")
+			bundleSample(t, bundlePath, BundleTar, []bundleEntry{{name: name, data: "evil", mode: 0644}})
+
+			out := filepath.Join(dir, "out")
+			if _, err := ImportBundle(bundlePath, out, NewFolderOptions("", "", GZip, false, 1)); err == nil {
+				t.Errorf("ImportBundle.err.nil")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+				t.Errorf("ImportBundle.written outside[%v]", err)
+			}
+		})
+	}
+}
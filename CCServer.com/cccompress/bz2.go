@@ -3,6 +3,7 @@ package cccompress
 import (
 	"bytes"
 	"github.com/dsnet/compress/bzip2"
+	"io"
 	"io/ioutil"
 )
 
@@ -45,6 +46,17 @@ func (p *CCBz2) Decompress(in []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+// NewWriter .
+func (p *CCBz2) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return bzip2.NewWriter(w, &bzip2.WriterConfig{
+		Level: p.CompressionLevel})
+}
+
+// NewReader .
+func (p *CCBz2) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return bzip2.NewReader(r, nil)
+}
+
 // NewBz2 .
 func NewBz2() *CCBz2 {
 	return &CCBz2{
@@ -54,3 +66,16 @@ func NewBz2() *CCBz2 {
 
 // DefaultBz2 .
 var DefaultBz2 = NewBz2()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:     Bz2,
+		Name:     "bz2",
+		Aliases:  []string{"bzip2"},
+		Default:  func() Codec { return DefaultBz2 },
+		New:      func(level int) Codec { return &CCBz2{CompressionLevel: level} },
+		MinLevel: bzip2.BestSpeed,
+		MaxLevel: bzip2.BestCompression,
+		Levels:   levelNames(bzip2.BestSpeed, bzip2.DefaultCompression, bzip2.BestCompression),
+	})
+}
@@ -5,10 +5,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"math"
 	"os"
 	"strings"
-	"sync"
 )
 
 var CCFormat = [...]byte{0x43, 0x2E, 0x43, 0x00}
@@ -22,13 +20,15 @@ const (
 	Bz2          = 3
 	Lzw          = 4
 	Lz4          = 5
+	Zstd         = 6
+	Brotli       = 7
 )
 
 // TagCCHeaderInfo .
 type TagCCHeaderInfo struct {
 	Format        [4]byte // 0x00 0x00 0x43 0x43
 	Version       [7]byte // 1050905
-	CompressMode  [1]byte // 0=Uncompressed、1=GZip、2=Zlib、3=Bz2、4=Lzw、5=Lz4
+	CompressMode  [1]byte // 0=Uncompressed、1=GZip、2=Zlib、3=Bz2、4=Lzw、5=Lz4、6=Zstd、7=Brotli
 	CompressedLen [8]byte // Length of compressed data.range:[0x00,0xFFFFFFFFFFFFFFFF]
 	OriginLen     [8]byte // Length before data compression.range:[0x00,0xFFFFFFFFFFFFFFFF]
 }
@@ -40,10 +40,7 @@ func IsValidFormat(s []byte) bool {
 
 // IsValidCompressMode .
 func IsValidCompressMode(s byte) bool {
-	if s < Uncompressed || s > Lz4 {
-		return false
-	}
-	return true
+	return CodecByMode(s) != nil
 }
 
 // IsValid .
@@ -65,17 +62,82 @@ func (p *TagCCHeaderInfo) IsValid() bool {
 	return true
 }
 
+// splitKey splits an obfuscation key "xxx.yyy",ok is false when key doesn't
+// ask for obfuscation.
+func splitKey(key string) (a []string, ok bool) {
+	a = strings.Split(key, ".")
+	return a, len(a) == 2
+}
+
+// CCOptions .
+type CCOptions struct {
+	Mode  byte
+	Codec Codec            // nil means the Default* codec of Mode
+	Ext   []TagCCHeaderExt // written in a v2 header,ignored without obfuscation key
+
+	// Checksum adds the CRC32 of src to Ext,checked when decompressing
+	Checksum bool
+}
+
+// obfuscateLen is how much of the body is obfuscated
+const obfuscateLen = 848
+
+// obfuscate xors the head of body with the two parts of the key in place,
+// applying it twice gives body back.
+func obfuscate(body []byte, a []string) {
+	x, y := len(a[0]), len(a[1])
+
+	total := len(body)
+	if total > obfuscateLen {
+		total = obfuscateLen
+	}
+
+	m, n := 0, 0
+	for i := 0; i < total/2; i++ {
+		body[i*2] ^= a[0][m]
+		body[i*2+1] ^= a[1][n]
+		if m < (x - 1) {
+			m++
+		} else {
+			m = 0
+		}
+		if n < (y - 1) {
+			n++
+		} else {
+			n = 0
+		}
+	}
+}
+
+// codecName .
+func codecName(mode byte) string {
+	if info := CodecByMode(mode); info != nil {
+		return info.Name
+	}
+	return fmt.Sprintf("mode[%v]", mode)
+}
+
 // Compress .
 func Compress(key string, src []byte, compressMode byte) (ret []byte, err error) {
+	return CompressWithOptions(key, src, &CCOptions{Mode: compressMode})
+}
+
+// CompressWithOptions .
+func CompressWithOptions(key string, src []byte, opts *CCOptions) (ret []byte, err error) {
+	if opts == nil {
+		return nil, fmt.Errorf("Compress[%v].opts nil", key)
+	}
+	compressMode := opts.Mode
+
 	if src == nil {
 		return nil, fmt.Errorf("Compress[%v].src nil", key)
 	}
 
 	Obfuscation := false
-	var header *TagCCHeaderInfo
+	var header *CCHeader
 	var x, y int
-	a := strings.Split(key, ".")
-	if len(a) == 2 {
+	a, ok := splitKey(key)
+	if ok {
 		//return nil, fmt.Errorf("Compress[%v].Split less", key)
 		x = len(a[0])
 		y = len(a[1])
@@ -91,71 +153,32 @@ func Compress(key string, src []byte, compressMode byte) (ret []byte, err error)
 	}
 	sLen := len(src)
 
-	var dst []byte
-	switch compressMode {
-	case GZip:
-		dst, err = DefaultGzip.Compress(src)
-		if err != nil {
-			return nil, fmt.Errorf("Compress[%v].GZip.Compress.err[%v]", key, err)
-		}
-	case Zlib:
-		dst, err = DefaultZlib.Compress(src)
-		if err != nil {
-			return nil, fmt.Errorf("Compress[%v].Zlib.Compress.err[%v]", key, err)
-		}
-	case Bz2:
-		dst, err = DefaultBz2.Compress(src)
-		if err != nil {
-			return nil, fmt.Errorf("Compress[%v].Bz2.Compress.err[%v]", key, err)
-		}
-	case Lzw:
-		dst, err = DefaultLzw.Compress(src)
-		if err != nil {
-			return nil, fmt.Errorf("Compress[%v].Lzw.Compress.err[%v]", key, err)
-		}
-	case Lz4:
-		dst, err = DefaultLz4.Compress(src)
-		if err != nil {
-			return nil, fmt.Errorf("Compress[%v].Lz4.Compress.err[%v]", key, err)
+	codec := opts.Codec
+	if codec == nil {
+		info := CodecByMode(compressMode)
+		if info == nil {
+			return nil, fmt.Errorf("Compress[%v].mode[%v].unknown", key, compressMode)
 		}
-	default:
-		dst = make([]byte, len(src))
-		copy(dst, src)
+		codec = info.Default()
+	}
+
+	dst, err := codec.Compress(src)
+	if err != nil {
+		return nil, fmt.Errorf("Compress[%v].%v.Compress.err[%v]", key, codecName(compressMode), err)
 	}
 
 	buf := new(bytes.Buffer)
 	if Obfuscation {
-		total := len(dst)
-		if total > 848 {
-			total = 848
-		}
-
-		m, n := 0, 0
-		for i := 0; i < total/2; i++ {
-			dst[i*2] ^= a[0][m]
-			dst[i*2+1] ^= a[1][n]
-			if m < (x - 1) {
-				m++
-			} else {
-				m = 0
-			}
-			if n < (y - 1) {
-				n++
-			} else {
-				n = 0
-			}
-		}
+		obfuscate(dst, a)
 
 		// make header
-		header = &TagCCHeaderInfo{
-			Format:       CCFormat,
-			CompressMode: [...]byte{compressMode},
+		ext := opts.Ext
+		if opts.Checksum {
+			ext = append(ext[:len(ext):len(ext)], ChecksumExt(src))
 		}
-		copy(header.Version[:], CCVersion)
-		copy(header.CompressedLen[:], ccutility.Int64ToBytes(int64(len(dst))))
-		copy(header.OriginLen[:], ccutility.Int64ToBytes(int64(sLen)))
+		header = newHeader(compressMode, len(dst), sLen, ext)
 
-		if err = binary.Write(buf, binary.LittleEndian, header); err != nil {
+		if err = header.write(buf); err != nil {
 			return nil, fmt.Errorf("Compress[%v].binary.Write.err[%v]", key, err)
 		}
 	}
@@ -168,7 +191,7 @@ func Compress(key string, src []byte, compressMode byte) (ret []byte, err error)
 }
 
 // Decompress .
-func Decompress(key string, src []byte, compressMode byte) (header *TagCCHeaderInfo, ret []byte, err error) {
+func Decompress(key string, src []byte, compressMode byte) (header *CCHeader, ret []byte, err error) {
 	if src == nil {
 		return nil, nil, fmt.Errorf("Decompress[%v].src.nil", key)
 	}
@@ -176,8 +199,8 @@ func Decompress(key string, src []byte, compressMode byte) (header *TagCCHeaderI
 	var srcBody = src
 	var x, y int
 	var realCompressMode = compressMode
-	a := strings.Split(key, ".")
-	if len(a) == 2 {
+	a, ok := splitKey(key)
+	if ok {
 		//return nil, nil, fmt.Errorf("Decompress[%v].Split less", key)
 		x = len(a[0])
 		y = len(a[1])
@@ -196,75 +219,72 @@ func Decompress(key string, src []byte, compressMode byte) (header *TagCCHeaderI
 			return nil, nil, fmt.Errorf("Decompress[%v].header.nil", key)
 		}
 
-		srcBody = src[binary.Size(header):]
-
-		total := len(srcBody)
-		if total > 848 {
-			total = 848
-		}
-		m, n := 0, 0
-		for i := 0; i < total/2; i++ {
-			srcBody[i*2] ^= a[0][m]
-			srcBody[i*2+1] ^= a[1][n]
-			if m < (x - 1) {
-				m++
-			} else {
-				m = 0
-			}
-			if n < (y - 1) {
-				n++
-			} else {
-				n = 0
-			}
+		if header.Size() > len(src) {
+			return nil, nil, fmt.Errorf("Decompress[%v].header size[%v/%v].more than src", key, header.Size(), len(src))
 		}
+		srcBody = src[header.Size():]
+
+		obfuscate(srcBody, a)
 		realCompressMode = header.CompressMode[0]
 	}
 
-	var dst []byte
-	switch realCompressMode {
-	case GZip:
-		dst, err = DefaultGzip.Decompress(srcBody)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Decompress[%v].GZip.Compress.err[%v]", key, err)
-		}
-	case Zlib:
-		dst, err = DefaultZlib.Decompress(srcBody)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Decompress[%v].Zlib.Compress.err[%v]", key, err)
-		}
-	case Bz2:
-		dst, err = DefaultBz2.Decompress(srcBody)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Decompress[%v].Bz2.Compress.err[%v]", key, err)
-		}
-	case Lzw:
-		dst, err = DefaultLzw.Decompress(srcBody)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Decompress[%v].Lzw.Compress.err[%v]", key, err)
-		}
-	case Lz4:
-		dst, err = DefaultLz4.Decompress(srcBody)
-		if err != nil {
-			return nil, nil, fmt.Errorf("Decompress[%v].Lz4.Compress.err[%v]", key, err)
+	info := CodecByMode(realCompressMode)
+	if info == nil {
+		return nil, nil, fmt.Errorf("Decompress[%v].mode[%v].unknown", key, realCompressMode)
+	}
+
+	dst, err := info.Default().Decompress(srcBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Decompress[%v].%v.Decompress.err[%v]", key, info.Name, err)
+	}
+	if header != nil {
+		if err = header.Check(dst); err != nil {
+			return nil, nil, fmt.Errorf("Decompress[%v].err[%v]", key, err)
 		}
-	default:
-		dst = make([]byte, len(srcBody))
-		copy(dst, srcBody)
 	}
 
 	return header, dst, nil
 }
 
+// Unwrap returns the body of src as its codec wrote it: without the header
+// and no longer obfuscated. header is nil without obfuscation key,body is
+// then src itself. src is left untouched.
+func Unwrap(key string, src []byte) (header *CCHeader, body []byte, err error) {
+	a, ok := splitKey(key)
+	if !ok {
+		return nil, src, nil
+	}
+	header, err = getHeader(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unwrap[%v].err[%v]", key, err)
+	}
+	if header.Size() > len(src) {
+		return nil, nil, fmt.Errorf("Unwrap[%v].header size[%v/%v].more than src", key, header.Size(), len(src))
+	}
+	body = make([]byte, len(src)-header.Size())
+	copy(body, src[header.Size():])
+	obfuscate(body, a)
+	return header, body, nil
+}
+
 // getHeader .
-func getHeader(src []byte) (header *TagCCHeaderInfo, err error) {
-	header = &TagCCHeaderInfo{}
-	if len(src) < binary.Size(header) {
+func getHeader(src []byte) (header *CCHeader, err error) {
+	return parseHeader(src, int64(len(src)))
+}
+
+// parseHeader checks the header at the start of src against the total size
+// of the file,src may be just a prefix of the file. The extensions are only
+// parsed when src holds all of them.
+func parseHeader(src []byte, total int64) (header *CCHeader, err error) {
+	header = &CCHeader{}
+	fixed := binary.Size(header.TagCCHeaderInfo)
+	if len(src) < fixed {
 		return nil, fmt.Errorf("getHeader.src.nil")
 	}
 
 	buf := bytes.NewBuffer(src)
 
-	if err := binary.Read(buf, binary.LittleEndian, header); err != nil {
+	if err := binary.Read(buf, binary.LittleEndian, &header.TagCCHeaderInfo); err != nil {
 		return nil, fmt.Errorf("getHeader.Read.err[%v]", err)
 	}
 
@@ -272,133 +292,130 @@ func getHeader(src []byte) (header *TagCCHeaderInfo, err error) {
 		return nil, fmt.Errorf("getHeader.header.IsValid.false")
 	}
 
-	l := ccutility.BytesToInt64(header.CompressedLen[:])
-
-	bodySize := len(src) - binary.Size(header)
-	if int(l) != bodySize {
-		return nil, fmt.Errorf("getHeader.size[%v/%v].no match", l, bodySize)
+	size := int64(fixed)
+	if header.IsV2() {
+		if len(src) < fixed+extLenSize {
+			return nil, fmt.Errorf("getHeader.ext.size less")
+		}
+		extLen := int64(binary.BigEndian.Uint32(src[fixed:]))
+		size += extLenSize + extLen
+		if int64(len(src)) >= size {
+			if err = header.readExt(src[fixed+extLenSize : size]); err != nil {
+				return nil, err
+			}
+		}
 	}
 
+	header.size = int(size)
+
+	if err = header.checkSize(total); err != nil {
+		return nil, err
+	}
 	return header, nil
 }
 
 // CompressFile .
 func CompressFile(filePath string, key string, compressMode int, bOverWrite bool) (dlen int64, err error) {
-	src, err := ccutility.ReadBinary(filePath)
+	return compressFile(filePath, filePath, key, CodecSpec{Mode: byte(compressMode)}, backupPath(filePath, bOverWrite))
+}
+
+// CompressFileTo compresses srcPath into dstPath,srcPath is left untouched.
+func CompressFileTo(srcPath string, dstPath string, key string, compressMode int) (dlen int64, err error) {
+	return compressFile(srcPath, dstPath, key, CodecSpec{Mode: byte(compressMode)}, "")
+}
+
+// CompressFileSpec is CompressFile with a codec level.
+func CompressFileSpec(filePath string, key string, spec CodecSpec, bOverWrite bool) (dlen int64, err error) {
+	return compressFile(filePath, filePath, key, spec, backupPath(filePath, bOverWrite))
+}
+
+// CompressFileSpecTo is CompressFileTo with a codec level.
+func CompressFileSpecTo(srcPath string, dstPath string, key string, spec CodecSpec) (dlen int64, err error) {
+	return compressFile(srcPath, dstPath, key, spec, "")
+}
+
+// compressFile .
+func compressFile(srcPath string, dstPath string, key string, spec CodecSpec, bak string) (dlen int64, err error) {
+	codec, err := spec.Codec()
 	if err != nil {
-		return 0, fmt.Errorf("CompressFile[%v].ReadBinary.err[%v]", filePath, err)
+		return 0, fmt.Errorf("CompressFile[%v].err[%v]", srcPath, err)
 	}
-	dst, err := Compress(key, src, byte(compressMode))
+	fi, err := os.Stat(srcPath)
 	if err != nil {
-		return 0, fmt.Errorf("CompressFile[%v].Compress.err[%v]", filePath, err)
+		return 0, fmt.Errorf("CompressFile[%v].Stat.err[%v]", srcPath, err)
 	}
-	if !bOverWrite {
-		os.Rename(filePath, filePath+".bak")
+	src, err := ccutility.ReadBinary(srcPath)
+	if err != nil {
+		return 0, fmt.Errorf("CompressFile[%v].ReadBinary.err[%v]", srcPath, err)
 	}
-	return ccutility.WriteBinary(filePath, dst)
+	dst, err := CompressWithOptions(key, src, &CCOptions{Mode: spec.Mode, Codec: codec, Ext: FileInfoExt(fi), Checksum: true})
+	if err != nil {
+		return 0, fmt.Errorf("CompressFile[%v].Compress.err[%v]", srcPath, err)
+	}
+	dlen, err = ccutility.WriteBinaryWithBackup(dstPath, dst, bak)
+	if err != nil {
+		return 0, err
+	}
+	// the compressed file keeps mode and mtime too,for timestamp based sync
+	if err = ccutility.CopyFileInfo(dstPath, fi); err != nil {
+		return 0, fmt.Errorf("CompressFile[%v].err[%v]", srcPath, err)
+	}
+	return dlen, nil
 }
 
 // DecompressFile .
 func DecompressFile(filePath string, key string, compressMode int, bOverWrite bool) (dlen int64, err error) {
-	src, err := ccutility.ReadBinary(filePath)
+	return decompressFile(filePath, filePath, key, compressMode, backupPath(filePath, bOverWrite))
+}
+
+// DecompressFileTo decompresses srcPath into dstPath,srcPath is left untouched.
+func DecompressFileTo(srcPath string, dstPath string, key string, compressMode int) (dlen int64, err error) {
+	return decompressFile(srcPath, dstPath, key, compressMode, "")
+}
+
+// decompressFile .
+func decompressFile(srcPath string, dstPath string, key string, compressMode int, bak string) (dlen int64, err error) {
+	fi, err := os.Stat(srcPath)
 	if err != nil {
-		return 0, fmt.Errorf("DecompressFile[%v].ReadBinary.err[%v]", filePath, err)
+		return 0, fmt.Errorf("DecompressFile[%v].Stat.err[%v]", srcPath, err)
 	}
-	_, dst, err := Decompress(key, src, byte(compressMode))
+	src, err := ccutility.ReadBinary(srcPath)
 	if err != nil {
-		return 0, fmt.Errorf("DecompressFile[%v].Decompress.err[%v]", filePath, err)
+		return 0, fmt.Errorf("DecompressFile[%v].ReadBinary.err[%v]", srcPath, err)
 	}
-	if !bOverWrite {
-		os.Rename(filePath, filePath+".bak")
+	header, dst, err := Decompress(key, src, byte(compressMode))
+	if err != nil {
+		return 0, fmt.Errorf("DecompressFile[%v].Decompress.err[%v]", srcPath, err)
 	}
-	return ccutility.WriteBinary(filePath, dst)
-}
-
-// CompressFolders .
-func CompressFolders(folders string, ext string, key string, compressMode int, bOverWrite bool, iWorkerNum int) (successed int64, err error) {
-	var allFile []string
-	allFile, err = ccutility.GetAllFileByExt(folders, ext, allFile)
+	dlen, err = ccutility.WriteBinaryWithBackup(dstPath, dst, bak)
 	if err != nil {
 		return 0, err
 	}
 
-	successed = 0
-
-	total := len(allFile)
-	pagePerCPU := 1
-
-	if total > iWorkerNum {
-		f := math.Ceil(float64(total) / float64(iWorkerNum))
-		pagePerCPU = ccutility.Round(f)
-	} else {
-		iWorkerNum = total
-		pagePerCPU = 1
-	}
-
-	ch := make(chan int, iWorkerNum)
-	var wg = &sync.WaitGroup{}
-	var lock = new(sync.RWMutex)
-
-	for i := 0; i < iWorkerNum; i++ {
-		wg.Add(1)
-		go func(ch <-chan int, wg *sync.WaitGroup, i int, t int, p int, f []string, k string, m int, w bool) {
-			defer wg.Done()
-			for idx := i * p; idx < (i+1)*p; idx++ {
-				if idx >= t {
-					break
-				}
-				if _, err = CompressFile(f[idx], k, m, w); err == nil {
-					lock.Lock()
-					successed++
-					lock.Unlock()
-				}
-			}
-		}(ch, wg, i, total, pagePerCPU, allFile, key, compressMode, bOverWrite)
+	// prefer what the header kept,else the compressed file had it preserved
+	if err = ccutility.CopyFileInfo(dstPath, fi); err != nil {
+		return 0, fmt.Errorf("DecompressFile[%v].err[%v]", srcPath, err)
 	}
-	wg.Wait()
-	return successed, err
+	if header != nil {
+		mode, hasMode := header.FileMode()
+		modTime, hasTime := header.ModTime()
+		if !hasMode {
+			mode = fi.Mode()
+		}
+		if !hasTime {
+			modTime = fi.ModTime()
+		}
+		if err = ccutility.ApplyFileInfo(dstPath, mode, modTime); err != nil {
+			return 0, fmt.Errorf("DecompressFile[%v].err[%v]", srcPath, err)
+		}
+	}
+	return dlen, nil
 }
 
-// DecompressFolders .
-func DecompressFolders(folders string, ext string, key string, compressMode int, bOverWrite bool, iWorkerNum int) (successed int64, err error) {
-	var allFile []string
-	allFile, err = ccutility.GetAllFileByExt(folders, ext, allFile)
-	if err != nil {
-		return 0, err
-	}
-	successed = 0
-
-	total := len(allFile)
-	pagePerCPU := 1
-
-	if total > iWorkerNum {
-		f := math.Ceil(float64(total) / float64(iWorkerNum))
-		pagePerCPU = ccutility.Round(f)
-	} else {
-		iWorkerNum = total
-		pagePerCPU = 1
-	}
-
-	ch := make(chan int, iWorkerNum)
-	var wg = &sync.WaitGroup{}
-	var lock = new(sync.RWMutex)
-
-	for i := 0; i < iWorkerNum; i++ {
-		wg.Add(1)
-		go func(ch <-chan int, wg *sync.WaitGroup, i int, t int, p int, f []string, k string, m int, w bool) {
-			defer wg.Done()
-			for idx := i * p; idx < (i+1)*p; idx++ {
-				if idx >= t {
-					break
-				}
-				if _, err = DecompressFile(f[idx], k, m, w); err == nil {
-					lock.Lock()
-					successed++
-					lock.Unlock()
-				}
-			}
-		}(ch, wg, i, total, pagePerCPU, allFile, key, compressMode, bOverWrite)
+// backupPath .
+func backupPath(filePath string, bOverWrite bool) string {
+	if bOverWrite {
+		return ""
 	}
-	wg.Wait()
-	return successed, err
+	return filePath + BackupExt
 }
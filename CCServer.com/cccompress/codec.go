@@ -0,0 +1,237 @@
+package cccompress
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec is implemented by CCGzip,CCZlib,CCBz2,CCLzw,CCLz4...
+type Codec interface {
+	Compress(in []byte) ([]byte, error)
+	Decompress(in []byte) ([]byte, error)
+}
+
+// StreamCodec is implemented by the codecs able to work on streams,every
+// registered one does.
+type StreamCodec interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// CCCodecInfo describes a compress mode.
+type CCCodecInfo struct {
+	Mode    byte
+	Name    string
+	Aliases []string
+
+	// HTTPCoding is the HTTP content coding of the format,"" when it has none
+	HTTPCoding string
+
+	// Default returns the Default* codec,so callers changing it still see it used
+	Default func() Codec
+
+	// New makes a codec with the given level,nil when the codec has no level
+	New      func(level int) Codec
+	MinLevel int
+	MaxLevel int
+	Levels   map[string]int // named levels,e.g. "fast","best"
+}
+
+var (
+	codecLock sync.RWMutex
+	codecs    = map[byte]*CCCodecInfo{}
+)
+
+// RegisterCodec adds or replaces the codec of info.Mode.
+func RegisterCodec(info *CCCodecInfo) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	codecs[info.Mode] = info
+}
+
+// CodecByMode .
+func CodecByMode(mode byte) *CCCodecInfo {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	return codecs[mode]
+}
+
+// CodecByName looks a codec up by name or alias,case insensitive.
+func CodecByName(name string) *CCCodecInfo {
+	name = strings.ToLower(name)
+
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	for _, info := range codecs {
+		if info.Name == name {
+			return info
+		}
+		for _, alias := range info.Aliases {
+			if alias == name {
+				return info
+			}
+		}
+	}
+	return nil
+}
+
+// Codecs returns every registered codec ordered by mode.
+func Codecs() []*CCCodecInfo {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+
+	ret := make([]*CCCodecInfo, 0, len(codecs))
+	for _, info := range codecs {
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Mode < ret[j].Mode
+	})
+	return ret
+}
+
+// CodecSpec is a compress mode with an optional level,written as
+// "name[:level]" e.g. "zstd:19","gzip:best","lz4". A bare number is read as
+// a mode,so "3" still means Bz2.
+type CodecSpec struct {
+	Mode     byte
+	Level    int
+	HasLevel bool
+}
+
+// ParseCodecSpec .
+func ParseCodecSpec(s string) (CodecSpec, error) {
+	var spec CodecSpec
+	name, level, hasLevel := strings.Cut(strings.TrimSpace(s), ":")
+
+	var info *CCCodecInfo
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 0 && n <= 0xFF {
+			info = CodecByMode(byte(n))
+		}
+	} else {
+		info = CodecByName(name)
+	}
+	if info == nil {
+		return spec, fmt.Errorf("ParseCodecSpec[%v].unknown codec", s)
+	}
+	spec.Mode = info.Mode
+
+	if !hasLevel {
+		return spec, nil
+	}
+	if info.New == nil {
+		return spec, fmt.Errorf("ParseCodecSpec[%v].%v has no level", s, info.Name)
+	}
+
+	l, ok := info.Levels[strings.ToLower(level)]
+	if !ok {
+		var err error
+		if l, err = strconv.Atoi(level); err != nil {
+			return spec, fmt.Errorf("ParseCodecSpec[%v].level invalid", s)
+		}
+	}
+	if l < info.MinLevel || l > info.MaxLevel {
+		return spec, fmt.Errorf("ParseCodecSpec[%v].level out of [%v,%v]", s, info.MinLevel, info.MaxLevel)
+	}
+	spec.Level = l
+	spec.HasLevel = true
+	return spec, nil
+}
+
+// String .
+func (p *CodecSpec) String() string {
+	name := strconv.Itoa(int(p.Mode))
+	if info := CodecByMode(p.Mode); info != nil {
+		name = info.Name
+	}
+	if p.HasLevel {
+		return fmt.Sprintf("%v:%v", name, p.Level)
+	}
+	return name
+}
+
+// Set makes a *CodecSpec usable as a flag.Value.
+func (p *CodecSpec) Set(s string) error {
+	spec, err := ParseCodecSpec(s)
+	if err != nil {
+		return err
+	}
+	*p = spec
+	return nil
+}
+
+// Codec returns a codec for the spec: the Default* one without a level,a new
+// one otherwise,so the globals are never changed.
+func (p *CodecSpec) Codec() (Codec, error) {
+	info := CodecByMode(p.Mode)
+	if info == nil {
+		return nil, fmt.Errorf("CodecSpec[%v].unknown mode", p.Mode)
+	}
+	if !p.HasLevel || info.New == nil {
+		return info.Default(), nil
+	}
+	return info.New(p.Level), nil
+}
+
+// levelNames are the names every codec with levels understands
+func levelNames(fast int, def int, best int) map[string]int {
+	return map[string]int{
+		"fast":    fast,
+		"fastest": fast,
+		"default": def,
+		"best":    best,
+	}
+}
+
+// CCStore keeps the data as is.
+type CCStore struct{}
+
+// Compress .
+func (p *CCStore) Compress(in []byte) ([]byte, error) {
+	out := make([]byte, len(in))
+	copy(out, in)
+	return out, nil
+}
+
+// Decompress .
+func (p *CCStore) Decompress(in []byte) ([]byte, error) {
+	return p.Compress(in)
+}
+
+// NewWriter .
+func (p *CCStore) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+// NewReader .
+func (p *CCStore) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(r), nil
+}
+
+// nopWriteCloser .
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close .
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// DefaultStore .
+var DefaultStore = &CCStore{}
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:    Uncompressed,
+		Name:    "none",
+		Aliases: []string{"store", "uncompressed"},
+		Default: func() Codec { return DefaultStore },
+	})
+}
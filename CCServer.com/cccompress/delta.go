@@ -0,0 +1,259 @@
+package cccompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"CCServer.com/ccutility"
+)
+
+// CCDeltaMagic starts a delta.
+var CCDeltaMagic = [...]byte{'C', '.', 'C', 'D'}
+
+// DefaultDeltaBlock is the block size of Diff,matches shorter than it are
+// not found.
+const DefaultDeltaBlock = 64
+
+// delta ops
+const (
+	deltaCopy = 'C' // Offset[uvarint] Len[uvarint],from the old data
+	deltaAdd  = 'A' // Len[uvarint] Data
+)
+
+// deltaMaxCandidates bounds the old blocks tried for a hash
+const deltaMaxCandidates = 16
+
+// Diff makes a delta turning old into data: the blocks of old are indexed by
+// a rolling hash,data is scanned for them and every match is grown both ways.
+// Layout: Magic[4] OldLen[uvarint] NewLen[uvarint] OldCRC32[4] NewCRC32[4]
+// then COPY/ADD ops. blockSize <= 0 means DefaultDeltaBlock.
+func Diff(old []byte, data []byte, blockSize int) []byte {
+	if blockSize <= 0 {
+		blockSize = DefaultDeltaBlock
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(CCDeltaMagic[:])
+	writeUvarint(buf, uint64(len(old)))
+	writeUvarint(buf, uint64(len(data)))
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(old))
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(data))
+
+	index := map[uint32][]int{}
+	for off := 0; off+blockSize <= len(old); off += blockSize {
+		h := newRollingHash(old[off : off+blockSize]).sum()
+		if len(index[h]) < deltaMaxCandidates {
+			index[h] = append(index[h], off)
+		}
+	}
+
+	lit := 0 // start of the pending ADD
+	i := 0
+	var rh *rollingHash
+	for i+blockSize <= len(data) {
+		if rh == nil {
+			rh = newRollingHash(data[i : i+blockSize])
+		}
+
+		off, n := -1, 0
+		for _, cand := range index[rh.sum()] {
+			if !bytes.Equal(old[cand:cand+blockSize], data[i:i+blockSize]) {
+				continue
+			}
+			l := blockSize
+			for cand+l < len(old) && i+l < len(data) && old[cand+l] == data[i+l] {
+				l++
+			}
+			if l > n {
+				off, n = cand, l
+			}
+		}
+
+		if off < 0 {
+			if i+blockSize < len(data) {
+				rh.roll(data[i], data[i+blockSize])
+			}
+			i++
+			continue
+		}
+
+		// grow back into the pending ADD
+		for off > 0 && i > lit && old[off-1] == data[i-1] {
+			off--
+			i--
+			n++
+		}
+		if i > lit {
+			buf.WriteByte(deltaAdd)
+			writeUvarint(buf, uint64(i-lit))
+			buf.Write(data[lit:i])
+		}
+		buf.WriteByte(deltaCopy)
+		writeUvarint(buf, uint64(off))
+		writeUvarint(buf, uint64(n))
+		i += n
+		lit = i
+		rh = nil
+	}
+
+	if lit < len(data) {
+		buf.WriteByte(deltaAdd)
+		writeUvarint(buf, uint64(len(data)-lit))
+		buf.Write(data[lit:])
+	}
+	return buf.Bytes()
+}
+
+// ApplyDelta rebuilds the new data from old and a delta made by Diff. old
+// must be the data the delta was made from.
+func ApplyDelta(old []byte, delta []byte) ([]byte, error) {
+	if len(delta) < len(CCDeltaMagic) || !bytes.Equal(delta[:len(CCDeltaMagic)], CCDeltaMagic[:]) {
+		return nil, fmt.Errorf("ApplyDelta.magic.no match")
+	}
+	r := bytes.NewReader(delta[len(CCDeltaMagic):])
+	oldLen, err1 := binary.ReadUvarint(r)
+	newLen, err2 := binary.ReadUvarint(r)
+	var oldCRC, newCRC uint32
+	err3 := binary.Read(r, binary.BigEndian, &oldCRC)
+	err4 := binary.Read(r, binary.BigEndian, &newCRC)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return nil, fmt.Errorf("ApplyDelta.header.broken")
+	}
+	if oldLen != uint64(len(old)) || oldCRC != crc32.ChecksumIEEE(old) {
+		return nil, fmt.Errorf("ApplyDelta.old data.no match")
+	}
+	// newLen isn't trusted for the allocation
+	capHint := newLen
+	if capHint > 1<<26 {
+		capHint = 1 << 26
+	}
+	dst := make([]byte, 0, capHint)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case deltaCopy:
+			off, err1 := binary.ReadUvarint(r)
+			n, err2 := binary.ReadUvarint(r)
+			if err1 != nil || err2 != nil || off > uint64(len(old)) || n > uint64(len(old))-off {
+				return nil, fmt.Errorf("ApplyDelta.copy.broken")
+			}
+			dst = append(dst, old[off:off+n]...)
+		case deltaAdd:
+			n, err := binary.ReadUvarint(r)
+			if err != nil || n > uint64(r.Len()) {
+				return nil, fmt.Errorf("ApplyDelta.add.broken")
+			}
+			data := make([]byte, n)
+			r.Read(data)
+			dst = append(dst, data...)
+		default:
+			return nil, fmt.Errorf("ApplyDelta.op[%v].unknown", op)
+		}
+		if uint64(len(dst)) > newLen {
+			return nil, fmt.Errorf("ApplyDelta.size.overflow")
+		}
+	}
+	if uint64(len(dst)) != newLen || crc32.ChecksumIEEE(dst) != newCRC {
+		return nil, fmt.Errorf("ApplyDelta.result.no match")
+	}
+	return dst, nil
+}
+
+// writeUvarint .
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// rollingHash is the weak checksum of rsync over a window.
+type rollingHash struct {
+	a, b uint32
+	n    uint32
+}
+
+// newRollingHash .
+func newRollingHash(window []byte) *rollingHash {
+	p := &rollingHash{n: uint32(len(window))}
+	for i, c := range window {
+		p.a += uint32(c)
+		p.b += uint32(len(window)-i) * uint32(c)
+	}
+	return p
+}
+
+// roll moves the window one byte forward.
+func (p *rollingHash) roll(out byte, in byte) {
+	p.a = p.a - uint32(out) + uint32(in)
+	p.b = p.b - p.n*uint32(out) + p.a
+}
+
+// sum .
+func (p *rollingHash) sum() uint32 {
+	return p.a&0xFFFF | p.b<<16
+}
+
+// DiffFile writes to patchPath the delta from oldPath to newPath,as a CC
+// container compressed with spec and obfuscated with key.
+func DiffFile(oldPath string, newPath string, patchPath string, key string, spec CodecSpec) (dlen int64, err error) {
+	old, err := ccutility.ReadBinary(oldPath)
+	if err != nil {
+		return 0, fmt.Errorf("DiffFile[%v].ReadBinary.err[%v]", oldPath, err)
+	}
+	src, err := ccutility.ReadBinary(newPath)
+	if err != nil {
+		return 0, fmt.Errorf("DiffFile[%v].ReadBinary.err[%v]", newPath, err)
+	}
+	fi, err := os.Stat(newPath)
+	if err != nil {
+		return 0, fmt.Errorf("DiffFile[%v].Stat.err[%v]", newPath, err)
+	}
+
+	codec, err := spec.Codec()
+	if err != nil {
+		return 0, fmt.Errorf("DiffFile[%v].err[%v]", patchPath, err)
+	}
+	// the header keeps the mode and mtime of the new file
+	dst, err := CompressWithOptions(key, Diff(old, src, 0), &CCOptions{Mode: spec.Mode, Codec: codec, Ext: FileInfoExt(fi), Checksum: true})
+	if err != nil {
+		return 0, fmt.Errorf("DiffFile[%v].Compress.err[%v]", patchPath, err)
+	}
+	return ccutility.WriteBinary(patchPath, dst)
+}
+
+// PatchFile applies the patch at patchPath,made by DiffFile,to oldPath and
+// writes the result to dstPath,which may be oldPath.
+func PatchFile(oldPath string, patchPath string, dstPath string, key string, compressMode int) (dlen int64, err error) {
+	old, err := ccutility.ReadBinary(oldPath)
+	if err != nil {
+		return 0, fmt.Errorf("PatchFile[%v].ReadBinary.err[%v]", oldPath, err)
+	}
+	src, err := ccutility.ReadBinary(patchPath)
+	if err != nil {
+		return 0, fmt.Errorf("PatchFile[%v].ReadBinary.err[%v]", patchPath, err)
+	}
+	header, delta, err := Decompress(key, src, byte(compressMode))
+	if err != nil {
+		return 0, fmt.Errorf("PatchFile[%v].Decompress.err[%v]", patchPath, err)
+	}
+	dst, err := ApplyDelta(old, delta)
+	if err != nil {
+		return 0, fmt.Errorf("PatchFile[%v].err[%v]", oldPath, err)
+	}
+
+	if dlen, err = ccutility.WriteBinary(dstPath, dst); err != nil {
+		return 0, err
+	}
+	if header != nil {
+		mode, hasMode := header.FileMode()
+		modTime, hasTime := header.ModTime()
+		if hasMode && hasTime {
+			if err = ccutility.ApplyFileInfo(dstPath, mode.Perm(), modTime); err != nil {
+				return 0, fmt.Errorf("PatchFile[%v].err[%v]", dstPath, err)
+			}
+		}
+	}
+	return dlen, nil
+}
@@ -0,0 +1,150 @@
+package cccompress
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// deltaSample is n bytes of reproducible data.
+func deltaSample(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func TestDiffApplyDelta(t *testing.T) {
+	base := deltaSample(1, 64<<10)
+	edited := append([]byte(nil), base...)
+	copy(edited[1000:], "edited in place")
+
+	tests := []struct {
+		name string
+		old  []byte
+		data []byte
+	}{
+		{"empty", nil, nil},
+		{"empty old", nil, base[:300]},
+		{"empty new", base, nil},
+		{"identical", base, base},
+		{"edited", base, edited},
+		{"shifted", base, append([]byte("inserted at the start"), base...)},
+		{"truncated", base, base[:len(base)/2]},
+		{"shorter than a block", base[:10], base[:20]},
+		{"unrelated", base, deltaSample(2, 4096)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := Diff(tt.old, tt.data, 0)
+			got, err := ApplyDelta(tt.old, delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta.err[%v]", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Fatalf("ApplyDelta.result[%v/%v].no match", len(got), len(tt.data))
+			}
+		})
+	}
+
+	// a few bytes changed must not cost the whole file
+	if delta := Diff(base, edited, 0); len(delta) > 1024 {
+		t.Errorf("Diff.edited.size[%v].too large", len(delta))
+	}
+}
+
+func TestApplyDeltaCorrupted(t *testing.T) {
+	old := deltaSample(3, 8<<10)
+	data := append(append([]byte(nil), old[:4096]...), []byte("a new tail")...)
+	delta := Diff(old, data, 0)
+
+	other := append([]byte(nil), old...)
+	other[0] ^= 0xFF
+
+	badCRC := append([]byte(nil), delta...)
+	badCRC[len(CCDeltaMagic)+2+2+4] ^= 0xFF // NewCRC32,after the 2 byte lengths and OldCRC32
+
+	tests := []struct {
+		name  string
+		old   []byte
+		delta []byte
+	}{
+		{"nil", old, nil},
+		{"bad magic", old, append([]byte("C.CX"), delta[4:]...)},
+		{"header only", old, delta[:len(CCDeltaMagic)+2]},
+		{"truncated", old, delta[:len(delta)-3]},
+		{"bad crc", old, badCRC},
+		{"other old", other, delta},
+		{"unknown op", old, append(append([]byte(nil), delta...), 'X')},
+		{"copy out of range", old, append(append([]byte(nil), delta...), deltaCopy, 0xFF, 0xFF, 0x03, 0x10)},
+		{"add past the end", old, append(append([]byte(nil), delta...), deltaAdd, 0x7F)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyDelta(tt.old, tt.delta); err == nil {
+				t.Fatalf("ApplyDelta.err.nil")
+			}
+		})
+	}
+}
+
+func TestPatchFileMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{"plain", 0750, 0750},
+		{"setuid", os.ModeSetuid | os.ModeSetgid | 0755, 0755},
+		{"sticky", os.ModeSticky | 0700, 0700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldDir, newDir := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+			for _, d := range []string{oldDir, newDir} {
+				if err := os.MkdirAll(d, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			oldPath, newPath := filepath.Join(oldDir, "a.bin"), filepath.Join(newDir, "a.bin")
+			if err := os.WriteFile(oldPath, deltaSample(4, 4096), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(newPath, deltaSample(5, 4096), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(newPath, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			patchPath, dstPath := filepath.Join(dir, "a.patch"), filepath.Join(dir, "a.out")
+			if _, err := DiffFile(oldPath, newPath, patchPath, "xxx.yyy", CodecSpec{Mode: GZip}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := PatchFile(oldPath, patchPath, dstPath, "xxx.yyy", GZip); err != nil {
+				t.Fatal(err)
+			}
+			if fi, err := os.Stat(dstPath); err != nil {
+				t.Fatal(err)
+			} else if fi.Mode() != tt.want {
+				t.Errorf("PatchFile.mode[%v/%v]", fi.Mode(), tt.want)
+			}
+
+			setPath := filepath.Join(dir, "a.ccp")
+			opts := NewFolderOptions("", "xxx.yyy", GZip, false, 1)
+			if _, err := DiffFolders(oldDir, newDir, setPath, opts); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := PatchFolder(oldDir, setPath, "xxx.yyy"); err != nil {
+				t.Fatal(err)
+			}
+			if fi, err := os.Stat(oldPath); err != nil {
+				t.Fatal(err)
+			} else if fi.Mode() != tt.want {
+				t.Errorf("PatchFolder.mode[%v/%v]", fi.Mode(), tt.want)
+			}
+		})
+	}
+}
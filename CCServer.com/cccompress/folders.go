@@ -0,0 +1,361 @@
+package cccompress
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// CCFolderOptions .
+type CCFolderOptions struct {
+	Ext          string
+	Key          string
+	CompressMode int
+	OverWrite    bool
+	WorkerNum    int
+	Journal      string // path of the job journal,empty means no journal
+
+	// Spec overrides CompressMode with a mode and a level,nil means
+	// CompressMode at the level of its Default* codec.
+	Spec *CodecSpec
+
+	// Rules pick the codec of a file by its extension or a glob on its path
+	// relative to the folder,the first matching one wins. Files matching no
+	// rule use Spec/CompressMode.
+	Rules []CCCodecRule
+
+	// Filter selects the files to process,nil means every file ending with Ext
+	// (a comma separated list) that isn't excluded by a .ccignore file.
+	Filter *ccutility.FileFilter
+
+	// OutDir receives the results under the same relative paths,the source
+	// files are left untouched. Empty means process in place.
+	OutDir string
+
+	// Incremental only processes sources that are new or changed since the
+	// last run into OutDir,and removes outputs whose sources are gone.
+	// The state is kept in OutDir/.ccmanifest.json.
+	Incremental bool
+
+	// ContentStore makes CompressFoldersWithOptions write a content store
+	// into OutDir,see StoreFolders.
+	ContentStore bool
+
+	// HotUpdate writes a project.manifest and a version.manifest listing
+	// the compressed files after CompressFoldersWithOptions,nil means none.
+	HotUpdate *CCHotUpdateOptions
+
+	// SkipProcessed skips files that are already compressed when compressing,
+	// or not compressed when decompressing. Otherwise they are only reported.
+	SkipProcessed bool
+}
+
+// NewFolderOptions .
+func NewFolderOptions(ext string, key string, compressMode int, bOverWrite bool, iWorkerNum int) *CCFolderOptions {
+	return &CCFolderOptions{
+		Ext:          ext,
+		Key:          key,
+		CompressMode: compressMode,
+		OverWrite:    bOverWrite,
+		WorkerNum:    iWorkerNum,
+	}
+}
+
+// spec .
+func (p *CCFolderOptions) spec() CodecSpec {
+	if p.Spec != nil {
+		return *p.Spec
+	}
+	return CodecSpec{Mode: byte(p.CompressMode)}
+}
+
+// CompressFolders .
+func CompressFolders(folders string, ext string, key string, compressMode int, bOverWrite bool, iWorkerNum int) (successed int64, err error) {
+	return CompressFoldersWithOptions(folders, NewFolderOptions(ext, key, compressMode, bOverWrite, iWorkerNum))
+}
+
+// DecompressFolders .
+func DecompressFolders(folders string, ext string, key string, compressMode int, bOverWrite bool, iWorkerNum int) (successed int64, err error) {
+	return DecompressFoldersWithOptions(folders, NewFolderOptions(ext, key, compressMode, bOverWrite, iWorkerNum))
+}
+
+// CompressFoldersWithOptions .
+func CompressFoldersWithOptions(folders string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts != nil && opts.ContentStore {
+		return StoreFolders(folders, opts)
+	}
+	successed, err = processFolders("CompressFolders", folders, opts, alreadyCompressed, func(t *folderTask) error {
+		spec := opts.specFor(t.rel)
+		if t.dst == t.src {
+			_, err := CompressFileSpec(t.src, opts.Key, spec, opts.OverWrite)
+			return err
+		}
+		_, err := CompressFileSpecTo(t.src, t.dst, opts.Key, spec)
+		return err
+	})
+	if err == nil && opts.HotUpdate != nil {
+		err = WriteHotUpdateManifest(folders, opts)
+	}
+	return successed, err
+}
+
+// DecompressFoldersWithOptions .
+func DecompressFoldersWithOptions(folders string, opts *CCFolderOptions) (successed int64, err error) {
+	return processFolders("DecompressFolders", folders, opts, notCompressed, func(t *folderTask) error {
+		if t.dst == t.src {
+			_, err := DecompressFile(t.src, opts.Key, int(opts.specFor(t.rel).Mode), opts.OverWrite)
+			return err
+		}
+		_, err := DecompressFileTo(t.src, t.dst, opts.Key, int(opts.specFor(t.rel).Mode))
+		return err
+	})
+}
+
+// alreadyCompressed .
+func alreadyCompressed(t *folderTask, opts *CCFolderOptions) string {
+	mode, header, ok, err := SniffFile(t.src)
+	if err != nil || !ok {
+		return ""
+	}
+	if header {
+		return fmt.Sprintf("cc header.mode[%v]", mode)
+	}
+	return fmt.Sprintf("magic.mode[%v]", mode)
+}
+
+// notCompressed .
+func notCompressed(t *folderTask, opts *CCFolderOptions) string {
+	mode, header, ok, err := SniffFile(t.src)
+	if err != nil {
+		return ""
+	}
+	if _, obfuscation := splitKey(opts.Key); obfuscation {
+		if !header {
+			return "no cc header"
+		}
+		return ""
+	}
+	if header {
+		return "cc header without key"
+	}
+	want := opts.specFor(t.rel).Mode
+	switch want {
+	case Uncompressed, Lzw:
+		// nothing to sniff
+		return ""
+	}
+	if !ok || mode != want {
+		return fmt.Sprintf("no magic.mode[%v]", want)
+	}
+	return ""
+}
+
+// folderTask .
+type folderTask struct {
+	src string
+	dst string // same as src when processing in place
+	rel string // slash separated path relative to the folder
+}
+
+// folderTasks lists the matching files under folders,leaving out the hot
+// update manifests and the journal.
+func folderTasks(name string, folders string, opts *CCFolderOptions) ([]*folderTask, error) {
+	filter := opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(opts.Ext)
+	}
+
+	var allFile []string
+	allFile, err := ccutility.GetAllFileByFilter(folders, filter, allFile)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]bool{}
+	for abs := range opts.HotUpdate.outputs(folders, opts) {
+		outputs[abs] = true
+	}
+	if len(opts.Journal) > 0 {
+		if abs, e := filepath.Abs(opts.Journal); e == nil {
+			outputs[abs] = true
+		}
+	}
+	// an OutDir inside the folder holds the results of the last run
+	var nested string
+	if len(opts.OutDir) > 0 {
+		folderAbs, e1 := filepath.Abs(folders)
+		outAbs, e2 := filepath.Abs(opts.OutDir)
+		if e1 == nil && e2 == nil && outAbs != folderAbs && inDir(outAbs, folderAbs) {
+			nested = outAbs
+		}
+	}
+	tasks := make([]*folderTask, 0, len(allFile))
+	for _, f := range allFile {
+		abs, _ := filepath.Abs(f)
+		if outputs[abs] || (len(nested) > 0 && inDir(abs, nested)) {
+			continue
+		}
+		t := &folderTask{src: f, dst: f}
+		rel, e := filepath.Rel(folders, f)
+		if e != nil {
+			return nil, fmt.Errorf("%v[%v].Rel.err[%v]", name, f, e)
+		}
+		t.rel = filepath.ToSlash(rel)
+		if len(opts.OutDir) > 0 {
+			t.dst = filepath.Join(opts.OutDir, rel)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// inDir reports whether the absolute path abs is dir or is under it.
+func inDir(abs string, dir string) bool {
+	return abs == dir || strings.HasPrefix(abs, dir+string(filepath.Separator))
+}
+
+// processFolders runs fn over every matching file with opts.WorkerNum workers.
+// Files already recorded in the journal or unchanged since the last incremental
+// run are counted as successed without running fn.
+// skip tells why a file looks already processed,"" means it doesn't.
+func processFolders(name string, folders string, opts *CCFolderOptions, skip func(t *folderTask, opts *CCFolderOptions) string, fn func(t *folderTask) error) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("%v[%v].opts.nil", name, folders)
+	}
+	if opts.Incremental && len(opts.OutDir) == 0 {
+		return 0, fmt.Errorf("%v[%v].Incremental without OutDir", name, folders)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("%v[%v].err[%v]", name, folders, err)
+	}
+
+	tasks, err := folderTasks(name, folders, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var manifest *CCIncrementalManifest
+	if opts.Incremental {
+		manifest, err = LoadIncrementalManifest(filepath.Join(opts.OutDir, DefaultIncrementalManifest))
+		if err != nil {
+			return 0, err
+		}
+	}
+	params := incrementalParams(name, opts)
+
+	var journal *CCJournal
+	if len(opts.Journal) > 0 {
+		journal, err = OpenJournal(opts.Journal, params)
+		if err != nil {
+			return 0, err
+		}
+		defer journal.Close()
+	}
+
+	successed = 0
+	var skipped int64
+	var lock = new(sync.RWMutex)
+
+	runWorkers(len(tasks), opts.WorkerNum, func(idx int) {
+		t := tasks[idx]
+
+		if journal != nil && journal.IsDone(t.src) {
+			lock.Lock()
+			successed++
+			lock.Unlock()
+			return
+		}
+
+		var srcHash string
+		if manifest != nil {
+			var unchanged bool
+			if srcHash, unchanged = manifest.Unchanged(t.rel, params, t.src, t.dst); unchanged {
+				lock.Lock()
+				successed++
+				lock.Unlock()
+				return
+			}
+		}
+
+		if reason := skip(t, opts); len(reason) > 0 {
+			if opts.SkipProcessed {
+				log.Printf("%v[%v].skip[%v]", name, t.src, reason)
+				lock.Lock()
+				skipped++
+				lock.Unlock()
+				return
+			}
+			log.Printf("%v[%v].already processed?[%v]", name, t.src, reason)
+		}
+
+		e := fn(t)
+		if e == nil && manifest != nil {
+			e = manifest.Update(t.rel, params, srcHash, t.dst)
+		}
+		if e == nil && journal != nil {
+			e = journal.Done(t.src)
+		}
+
+		lock.Lock()
+		if e == nil {
+			successed++
+		} else {
+			log.Printf("%v[%v].err[%v]", name, t.src, e)
+			err = e
+		}
+		lock.Unlock()
+	})
+
+	if manifest != nil {
+		if e := manifest.Prune(folders, opts.OutDir); e != nil {
+			err = e
+		}
+		if e := manifest.Save(); e != nil {
+			err = e
+		}
+	}
+
+	if skipped > 0 {
+		log.Printf("%v[%v].skipped[%v]", name, folders, skipped)
+	}
+	return successed, err
+}
+
+// runWorkers splits [0,total) into iWorkerNum pages and runs fn over every
+// index,one goroutine per page.
+func runWorkers(total int, iWorkerNum int, fn func(idx int)) {
+	if iWorkerNum < 1 {
+		iWorkerNum = 1
+	}
+
+	pagePerCPU := 1
+
+	if total > iWorkerNum {
+		f := math.Ceil(float64(total) / float64(iWorkerNum))
+		pagePerCPU = ccutility.Round(f)
+	} else {
+		iWorkerNum = total
+		pagePerCPU = 1
+	}
+
+	var wg = &sync.WaitGroup{}
+
+	for i := 0; i < iWorkerNum; i++ {
+		wg.Add(1)
+		go func(wg *sync.WaitGroup, i int, t int, p int) {
+			defer wg.Done()
+			for idx := i * p; idx < (i+1)*p; idx++ {
+				if idx >= t {
+					break
+				}
+				fn(idx)
+			}
+		}(wg, i, total, pagePerCPU)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,368 @@
+package cccompress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// CCFS is an io/fs.FS over a compressed tree or a CC archive,files are
+// decompressed when opened and Stat reports their original size.
+//
+// Over a tree,a file with a CC header is decoded with the key,a file without
+// one with the mode given to NewFS,or served as is when that mode is
+// Uncompressed. Files are decoded in memory as a whole.
+type CCFS struct {
+	base fs.FS
+	key  string
+	mode byte
+
+	archive *CCArchiveReader
+	dirs    map[string][]string // archive folder -> sorted child names
+}
+
+var (
+	_ fs.ReadFileFS = (*CCFS)(nil)
+	_ fs.StatFS     = (*CCFS)(nil)
+	_ fs.ReadDirFS  = (*CCFS)(nil)
+)
+
+// NewFS serves base,e.g. os.DirFS("assets").
+func NewFS(base fs.FS, key string, compressMode byte) *CCFS {
+	return &CCFS{base: base, key: key, mode: compressMode}
+}
+
+// NewArchiveFS serves the entries of r,folders are made up from the entry
+// names.
+func NewArchiveFS(r *CCArchiveReader) *CCFS {
+	p := &CCFS{archive: r, dirs: map[string][]string{".": nil}}
+	seen := map[string]bool{}
+	for _, e := range r.Entries() {
+		for name := e.Name; name != "."; name = path.Dir(name) {
+			dir := path.Dir(name)
+			if seen[name] {
+				break
+			}
+			seen[name] = true
+			p.dirs[dir] = append(p.dirs[dir], path.Base(name))
+		}
+	}
+	for name, children := range p.dirs {
+		sort.Strings(children)
+		p.dirs[name] = children
+	}
+	return p
+}
+
+// NewBytesFS serves the CC archive held in data,e.g. one embedded with
+// go:embed.
+func NewBytesFS(data []byte, key string) (*CCFS, error) {
+	r, err := NewArchiveReader(bytes.NewReader(data), int64(len(data)), key)
+	if err != nil {
+		return nil, err
+	}
+	return NewArchiveFS(r), nil
+}
+
+// Open .
+func (p *CCFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if p.archive != nil {
+		return p.openArchive(name)
+	}
+
+	f, err := p.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		d, ok := f.(fs.ReadDirFile)
+		if !ok {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("not a ReadDirFile")}
+		}
+		return &ccBaseDir{ReadDirFile: d, p: p, name: name}, nil
+	}
+
+	src, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	dst, err := p.decode(src)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return newCCFile(&ccFileInfo{name: fi.Name(), size: int64(len(dst)), mode: fi.Mode(), modTime: fi.ModTime()}, dst), nil
+}
+
+// ReadFile .
+func (p *CCFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	if p.archive != nil {
+		e := p.archive.Entry(name)
+		if e == nil || e.Name != name {
+			return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+		}
+		return p.archive.ReadEntry(e)
+	}
+
+	src, err := fs.ReadFile(p.base, name)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := p.decode(src)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return dst, nil
+}
+
+// Stat reports the original size,from the CC header when it knows it.
+func (p *CCFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if p.archive != nil {
+		return p.statArchive(name)
+	}
+
+	fi, err := fs.Stat(p.base, name)
+	if err != nil || fi.IsDir() {
+		return fi, err
+	}
+
+	size, err := p.originSize(name, fi.Size())
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return &ccFileInfo{name: fi.Name(), size: size, mode: fi.Mode(), modTime: fi.ModTime()}, nil
+}
+
+// ReadDir .
+func (p *CCFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := p.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries, err := d.ReadDir(-1)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, err
+}
+
+// decode .
+func (p *CCFS) decode(src []byte) ([]byte, error) {
+	if _, err := getHeader(src); err == nil {
+		if _, ok := splitKey(p.key); !ok {
+			return nil, fmt.Errorf("CCFS.cc header without key")
+		}
+		_, dst, err := Decompress(p.key, src, p.mode)
+		return dst, err
+	}
+	if p.mode == Uncompressed {
+		return src, nil
+	}
+	_, dst, err := Decompress("", src, p.mode)
+	return dst, err
+}
+
+// originSize reads the header only when it knows the size,otherwise the
+// file is decoded.
+func (p *CCFS) originSize(name string, size int64) (int64, error) {
+	f, err := p.base.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+
+	if header, err := parseHeader(head[:n], size); err == nil {
+		if l := header.OriginSize(); l != StreamLen {
+			return l, nil
+		}
+	} else if p.mode == Uncompressed {
+		return size, nil
+	}
+
+	dst, err := p.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(dst)), nil
+}
+
+// openArchive .
+func (p *CCFS) openArchive(name string) (fs.File, error) {
+	fi, err := p.statArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return &ccDir{info: fi, entries: p.archiveDirEntries(name)}, nil
+	}
+	dst, err := p.archive.ReadEntry(p.archive.Entry(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return newCCFile(fi, dst), nil
+}
+
+// statArchive .
+func (p *CCFS) statArchive(name string) (fs.FileInfo, error) {
+	if e := p.archive.Entry(name); e != nil && e.Name == name {
+		return &ccFileInfo{name: path.Base(name), size: e.OriginSize, mode: e.FileMode, modTime: e.ModTime}, nil
+	}
+	if _, ok := p.dirs[name]; ok {
+		return &ccFileInfo{name: path.Base(name), mode: fs.ModeDir | 0755}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// archiveDirEntries .
+func (p *CCFS) archiveDirEntries(name string) []fs.DirEntry {
+	children := p.dirs[name]
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		fi, err := p.statArchive(path.Join(name, child))
+		if err == nil {
+			entries = append(entries, fs.FileInfoToDirEntry(fi))
+		}
+	}
+	return entries
+}
+
+// ccFileInfo .
+type ccFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (p *ccFileInfo) Name() string       { return p.name }
+func (p *ccFileInfo) Size() int64        { return p.size }
+func (p *ccFileInfo) Mode() fs.FileMode  { return p.mode }
+func (p *ccFileInfo) ModTime() time.Time { return p.modTime }
+func (p *ccFileInfo) IsDir() bool        { return p.mode.IsDir() }
+func (p *ccFileInfo) Sys() interface{}   { return nil }
+
+// ccFile is a decoded file,it can Seek and ReadAt as http.FS needs.
+type ccFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+// newCCFile .
+func newCCFile(info fs.FileInfo, data []byte) *ccFile {
+	return &ccFile{Reader: bytes.NewReader(data), info: info}
+}
+
+// Stat .
+func (p *ccFile) Stat() (fs.FileInfo, error) {
+	return p.info, nil
+}
+
+// Close .
+func (p *ccFile) Close() error {
+	return nil
+}
+
+// ccDir is an archive folder.
+type ccDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+// Stat .
+func (p *ccDir) Stat() (fs.FileInfo, error) {
+	return p.info, nil
+}
+
+// Read .
+func (p *ccDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: p.info.Name(), Err: errors.New("is a directory")}
+}
+
+// Close .
+func (p *ccDir) Close() error {
+	return nil
+}
+
+// ReadDir .
+func (p *ccDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := p.entries[p.offset:]
+	if n <= 0 {
+		p.offset = len(p.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	p.offset += n
+	return rest[:n], nil
+}
+
+// ccBaseDir is a folder of the base FS whose entries report original sizes.
+type ccBaseDir struct {
+	fs.ReadDirFile
+	p    *CCFS
+	name string
+}
+
+// ReadDir .
+func (d *ccBaseDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	for i, e := range entries {
+		if !e.IsDir() {
+			entries[i] = &ccDirEntry{DirEntry: e, p: d.p, name: path.Join(d.name, e.Name())}
+		}
+	}
+	return entries, err
+}
+
+// ccDirEntry .
+type ccDirEntry struct {
+	fs.DirEntry
+	p    *CCFS
+	name string
+}
+
+// Info .
+func (e *ccDirEntry) Info() (fs.FileInfo, error) {
+	return e.p.Stat(e.name)
+}
+
+// String .
+func (e *ccDirEntry) String() string {
+	return fs.FormatDirEntry(e)
+}
@@ -0,0 +1,98 @@
+package cccompress
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// fsSample is the original content of the trees served below.
+var fsSample = map[string]string{
+	"a.txt":             "hello fs",
+	"sub/b.json":        `{"k":"v"}`,
+	"sub/deep/c.md":     "# deep",
+	"sub/deep/empty.md": "",
+}
+
+func TestCCFS(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys func(t *testing.T) *CCFS
+	}{
+		{"tree with key", func(t *testing.T) *CCFS {
+			return NewFS(fsSampleTree(t, "xxx.yyy", GZip), "xxx.yyy", Zstd)
+		}},
+		{"tree without header", func(t *testing.T) *CCFS {
+			return NewFS(fsSampleTree(t, "", Zstd), "", Zstd)
+		}},
+		{"tree uncompressed", func(t *testing.T) *CCFS {
+			base := fstest.MapFS{}
+			for name, data := range fsSample {
+				base[name] = &fstest.MapFile{Data: []byte(data), Mode: 0644}
+			}
+			return NewFS(base, "", Uncompressed)
+		}},
+		{"archive", func(t *testing.T) *CCFS {
+			names := make([]string, 0, len(fsSample))
+			entries := map[string][]byte{}
+			for name, data := range fsSample {
+				names = append(names, name)
+				entries[name] = []byte(data)
+			}
+			fsys, err := NewBytesFS(archiveSample(t, "xxx.yyy", entries, names), "xxx.yyy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return fsys
+		}},
+	}
+	expected := make([]string, 0, len(fsSample))
+	for name := range fsSample {
+		expected = append(expected, name)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := tt.fsys(t)
+			if err := fstest.TestFS(fsys, expected...); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range fsSample {
+				got, err := fs.ReadFile(fsys, name)
+				if err != nil || string(got) != data {
+					t.Errorf("ReadFile[%v].got[%q].err[%v]", name, got, err)
+				}
+				fi, err := fs.Stat(fsys, name)
+				if err != nil || fi.Size() != int64(len(data)) {
+					t.Errorf("Stat[%v].err[%v]", name, err)
+				}
+			}
+			for _, name := range []string{"missing.txt", "sub/missing", "../a.txt", "/a.txt"} {
+				if _, err := fs.ReadFile(fsys, name); err == nil {
+					t.Errorf("ReadFile[%v].err.nil", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCCFSWrongKey(t *testing.T) {
+	fsys := NewFS(fsSampleTree(t, "xxx.yyy", GZip), "zzz.www", GZip)
+	if _, err := fs.ReadFile(fsys, "a.txt"); err == nil {
+		t.Errorf("ReadFile.wrong key.err.nil")
+	}
+}
+
+// fsSampleTree compresses fsSample into a MapFS,with a CC header when key
+// is set.
+func fsSampleTree(t *testing.T, key string, mode byte) fstest.MapFS {
+	t.Helper()
+	base := fstest.MapFS{}
+	for name, data := range fsSample {
+		dst, err := CompressWithOptions(key, []byte(data), &CCOptions{Mode: mode, Checksum: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		base[name] = &fstest.MapFile{Data: dst, Mode: 0644}
+	}
+	return base
+}
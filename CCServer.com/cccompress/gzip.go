@@ -3,6 +3,7 @@ package cccompress
 import (
 	"bytes"
 	"compress/gzip"
+	"io"
 	"io/ioutil"
 )
 
@@ -44,6 +45,16 @@ func (p *CCGzip) Decompress(in []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+// NewWriter .
+func (p *CCGzip) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, p.CompressionLevel)
+}
+
+// NewReader .
+func (p *CCGzip) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
 // NewGzip .
 func NewGzip() *CCGzip {
 	return &CCGzip{
@@ -53,3 +64,17 @@ func NewGzip() *CCGzip {
 
 // DefaultGzip .
 var DefaultGzip = NewGzip()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:       GZip,
+		Name:       "gzip",
+		Aliases:    []string{"gz"},
+		HTTPCoding: "gzip",
+		Default:    func() Codec { return DefaultGzip },
+		New:        func(level int) Codec { return &CCGzip{CompressionLevel: level} },
+		MinLevel:   gzip.HuffmanOnly,
+		MaxLevel:   gzip.BestCompression,
+		Levels:     levelNames(gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression),
+	})
+}
@@ -0,0 +1,312 @@
+package cccompress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"time"
+
+	"CCServer.com/ccutility"
+)
+
+// CCVersion2 marks a header followed by extensions:
+//
+//	TagCCHeaderInfo | ExtLen [4]byte | ExtLen bytes of records
+//
+// each record being Type [1]byte | Len [2]byte | Data. Integers are big endian
+// like the length fields of TagCCHeaderInfo. CompressedLen still counts the
+// body only.
+var CCVersion2 = []byte{'2', '0', '1', '0', '9', '0', '5'}
+
+// header extensions
+const (
+	ExtFileMode = 1 // [4]byte os.FileMode of the original file
+	ExtModTime  = 2 // [8]byte modification time of the original file,unix nano
+	ExtCRC32    = 3 // [4]byte CRC-32(IEEE) of the original data
+)
+
+// extLenSize .
+const extLenSize = 4
+
+// MaxExtLen bounds ExtLen,a larger one is taken as a broken header rather
+// than allocated.
+const MaxExtLen = 64 << 10
+
+// TagCCHeaderExt .
+type TagCCHeaderExt struct {
+	Type byte
+	Data []byte
+}
+
+// CCHeader is a parsed header with its v2 extensions.
+type CCHeader struct {
+	TagCCHeaderInfo
+	Ext []TagCCHeaderExt
+
+	size int // bytes before the body as read,0 for a header being built
+}
+
+// IsV2 .
+func (p *CCHeader) IsV2() bool {
+	return p.Version[0] >= '2'
+}
+
+// Size is the number of bytes before the body.
+func (p *CCHeader) Size() int {
+	if p.size > 0 {
+		return p.size
+	}
+	n := binary.Size(p.TagCCHeaderInfo)
+	if p.IsV2() {
+		n += extLenSize
+		for _, e := range p.Ext {
+			n += 3 + len(e.Data)
+		}
+	}
+	return n
+}
+
+// GetExt .
+func (p *CCHeader) GetExt(t byte) ([]byte, bool) {
+	for _, e := range p.Ext {
+		if e.Type == t {
+			return e.Data, true
+		}
+	}
+	return nil, false
+}
+
+// SetExt .
+func (p *CCHeader) SetExt(t byte, data []byte) {
+	for i := range p.Ext {
+		if p.Ext[i].Type == t {
+			p.Ext[i].Data = data
+			return
+		}
+	}
+	p.Ext = append(p.Ext, TagCCHeaderExt{Type: t, Data: data})
+}
+
+// FileMode .
+func (p *CCHeader) FileMode() (os.FileMode, bool) {
+	b, ok := p.GetExt(ExtFileMode)
+	if !ok || len(b) != 4 {
+		return 0, false
+	}
+	return os.FileMode(binary.BigEndian.Uint32(b)), true
+}
+
+// ModTime .
+func (p *CCHeader) ModTime() (time.Time, bool) {
+	b, ok := p.GetExt(ExtModTime)
+	if !ok || len(b) != 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ccutility.BytesToInt64(b)), true
+}
+
+// CRC32 .
+func (p *CCHeader) CRC32() (uint32, bool) {
+	b, ok := p.GetExt(ExtCRC32)
+	if !ok || len(b) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b), true
+}
+
+// Check checks decompressed data against OriginLen and the CRC32 extension,
+// when they are known.
+func (p *CCHeader) Check(dst []byte) error {
+	if l := p.OriginSize(); l != StreamLen && l != int64(len(dst)) {
+		return fmt.Errorf("CCHeader.origin size[%v/%v].no match", l, len(dst))
+	}
+	if sum, ok := p.CRC32(); ok && sum != crc32.ChecksumIEEE(dst) {
+		return fmt.Errorf("CCHeader.crc32[%08x/%08x].no match", sum, crc32.ChecksumIEEE(dst))
+	}
+	return nil
+}
+
+// ChecksumExt returns the CRC32 extension of src.
+func ChecksumExt(src []byte) TagCCHeaderExt {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(src))
+	return TagCCHeaderExt{Type: ExtCRC32, Data: sum}
+}
+
+// Mode is the compress mode of the body.
+func (p *CCHeader) Mode() byte {
+	return p.CompressMode[0]
+}
+
+// CompressedSize is the size of the body,StreamLen when unknown.
+func (p *CCHeader) CompressedSize() int64 {
+	return ccutility.BytesToInt64(p.CompressedLen[:])
+}
+
+// OriginSize is the size before compression,StreamLen when unknown.
+func (p *CCHeader) OriginSize() int64 {
+	return ccutility.BytesToInt64(p.OriginLen[:])
+}
+
+// Ratio is CompressedSize/OriginSize,0 when unknown.
+func (p *CCHeader) Ratio() float64 {
+	c, o := p.CompressedSize(), p.OriginSize()
+	if c == StreamLen || o == StreamLen || o == 0 {
+		return 0
+	}
+	return float64(c) / float64(o)
+}
+
+// checkSize checks the header size and CompressedLen against the total size
+// of the file,the lengths being read from untrusted data.
+func (p *CCHeader) checkSize(total int64) error {
+	bodySize := total - int64(p.Size())
+	if bodySize < 0 {
+		return fmt.Errorf("getHeader.header size[%v/%v].more than the file", p.Size(), total)
+	}
+	l, o := p.CompressedSize(), p.OriginSize()
+	if (l < 0 && l != StreamLen) || (o < 0 && o != StreamLen) {
+		return fmt.Errorf("getHeader.length[%v/%v].negative", l, o)
+	}
+	// a streamed header doesn't know the size,the body is the rest
+	if l != StreamLen && l != bodySize {
+		return fmt.Errorf("getHeader.size[%v/%v].no match", l, bodySize)
+	}
+	return nil
+}
+
+// ExtName .
+func ExtName(t byte) string {
+	switch t {
+	case ExtFileMode:
+		return "FileMode"
+	case ExtModTime:
+		return "ModTime"
+	case ExtCRC32:
+		return "CRC32"
+	}
+	return fmt.Sprintf("Ext%v", t)
+}
+
+// String decodes the known extensions,the others are shown in hex.
+func (p *TagCCHeaderExt) String() string {
+	h := CCHeader{Ext: []TagCCHeaderExt{*p}}
+	switch p.Type {
+	case ExtFileMode:
+		if mode, ok := h.FileMode(); ok {
+			return mode.String()
+		}
+	case ExtModTime:
+		if t, ok := h.ModTime(); ok {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return hex.EncodeToString(p.Data)
+}
+
+// ParseHeader parses the header at the start of src,src being a whole CC
+// file.
+func ParseHeader(src []byte) (*CCHeader, error) {
+	return getHeader(src)
+}
+
+// ReadHeader reads the header of a CC file without reading its body.
+func ReadHeader(filePath string) (*CCHeader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("ReadHeader[%v].Open.err[%v]", filePath, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ReadHeader[%v].Stat.err[%v]", filePath, err)
+	}
+	header, err := readHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("ReadHeader[%v].err[%v]", filePath, err)
+	}
+	if err = header.checkSize(fi.Size()); err != nil {
+		return nil, fmt.Errorf("ReadHeader[%v].err[%v]", filePath, err)
+	}
+	return header, nil
+}
+
+// FileInfoExt returns the extensions keeping the mode and mtime of fi.
+func FileInfoExt(fi os.FileInfo) []TagCCHeaderExt {
+	mode := make([]byte, 4)
+	binary.BigEndian.PutUint32(mode, uint32(fi.Mode()))
+	return []TagCCHeaderExt{
+		{Type: ExtFileMode, Data: mode},
+		{Type: ExtModTime, Data: ccutility.Int64ToBytes(fi.ModTime().UnixNano())},
+	}
+}
+
+// newHeader makes a v1 header,or a v2 one when there are extensions.
+func newHeader(compressMode byte, compressedLen int, originLen int, ext []TagCCHeaderExt) *CCHeader {
+	header := &CCHeader{
+		TagCCHeaderInfo: TagCCHeaderInfo{
+			Format:       CCFormat,
+			CompressMode: [...]byte{compressMode},
+		},
+		Ext: ext,
+	}
+	if len(ext) > 0 {
+		copy(header.Version[:], CCVersion2)
+	} else {
+		copy(header.Version[:], CCVersion)
+	}
+	copy(header.CompressedLen[:], ccutility.Int64ToBytes(int64(compressedLen)))
+	copy(header.OriginLen[:], ccutility.Int64ToBytes(int64(originLen)))
+	return header
+}
+
+// write .
+func (p *CCHeader) write(buf *bytes.Buffer) error {
+	if err := binary.Write(buf, binary.LittleEndian, &p.TagCCHeaderInfo); err != nil {
+		return err
+	}
+	if !p.IsV2() {
+		return nil
+	}
+
+	ext := new(bytes.Buffer)
+	for _, e := range p.Ext {
+		if len(e.Data) > 0xFFFF {
+			return fmt.Errorf("CCHeader.ext[%v].too large[%v]", e.Type, len(e.Data))
+		}
+		ext.WriteByte(e.Type)
+		binary.Write(ext, binary.BigEndian, uint16(len(e.Data)))
+		ext.Write(e.Data)
+	}
+	if ext.Len() > MaxExtLen {
+		return fmt.Errorf("CCHeader.ext.too large[%v]", ext.Len())
+	}
+	binary.Write(buf, binary.BigEndian, uint32(ext.Len()))
+	_, err := ext.WriteTo(buf)
+	return err
+}
+
+// readExt parses the extension records of a v2 header.
+func (p *CCHeader) readExt(src []byte) error {
+	for len(src) > 0 {
+		if len(src) < 3 {
+			return fmt.Errorf("getHeader.ext.record broken")
+		}
+		t := src[0]
+		n := int(binary.BigEndian.Uint16(src[1:3]))
+		src = src[3:]
+		if len(src) < n {
+			return fmt.Errorf("getHeader.ext[%v].size[%v/%v].less", t, n, len(src))
+		}
+		data := make([]byte, n)
+		copy(data, src[:n])
+		p.Ext = append(p.Ext, TagCCHeaderExt{Type: t, Data: data})
+		src = src[n:]
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package cccompress
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// hot update manifest file names
+const (
+	ProjectManifest = "project.manifest"
+	VersionManifest = "version.manifest"
+)
+
+// CCHotUpdateOptions .
+type CCHotUpdateOptions struct {
+	// Dir receives the manifests,empty means OutDir or else the folder.
+	Dir string
+
+	// PackageURL is where the clients download the compressed files from,
+	// their paths are relative to it.
+	PackageURL string
+
+	// RemoteManifestURL and RemoteVersionURL default to the manifest names
+	// under PackageURL.
+	RemoteManifestURL string
+	RemoteVersionURL  string
+
+	Version       string
+	EngineVersion string
+	SearchPaths   []string
+}
+
+// CCHotUpdateAsset is a file of the manifest. Size and MD5 are those of the
+// file downloaded,the Origin* ones those of the data once decoded.
+type CCHotUpdateAsset struct {
+	Size       int64  `json:"size"`
+	MD5        string `json:"md5"`
+	OriginSize int64  `json:"originSize"`
+	OriginMD5  string `json:"originMd5"`
+	Codec      string `json:"codec"`
+}
+
+// CCHotUpdateManifest is the project.manifest,the version.manifest is the
+// same without Assets and SearchPaths.
+type CCHotUpdateManifest struct {
+	PackageURL        string                       `json:"packageUrl"`
+	RemoteManifestURL string                       `json:"remoteManifestUrl"`
+	RemoteVersionURL  string                       `json:"remoteVersionUrl"`
+	Version           string                       `json:"version"`
+	EngineVersion     string                       `json:"engineVersion,omitempty"`
+	Assets            map[string]*CCHotUpdateAsset `json:"assets,omitempty"`
+	SearchPaths       []string                     `json:"searchPaths,omitempty"`
+}
+
+// dir .
+func (p *CCHotUpdateOptions) dir(folders string, opts *CCFolderOptions) string {
+	if len(p.Dir) > 0 {
+		return p.Dir
+	}
+	if len(opts.OutDir) > 0 {
+		return opts.OutDir
+	}
+	return folders
+}
+
+// outputs are the absolute paths of the manifests,so a folder isn't
+// compressed together with its own manifests.
+func (p *CCHotUpdateOptions) outputs(folders string, opts *CCFolderOptions) map[string]bool {
+	if p == nil {
+		return nil
+	}
+	ret := map[string]bool{}
+	for _, name := range []string{ProjectManifest, VersionManifest} {
+		if abs, err := filepath.Abs(filepath.Join(p.dir(folders, opts), name)); err == nil {
+			ret[abs] = true
+		}
+	}
+	return ret
+}
+
+// remoteURL .
+func (p *CCHotUpdateOptions) remoteURL(url string, name string) string {
+	if len(url) > 0 || len(p.PackageURL) == 0 {
+		return url
+	}
+	return strings.TrimSuffix(p.PackageURL, "/") + "/" + name
+}
+
+// BuildHotUpdateManifest lists the compressed files of folders,i.e. those
+// under opts.OutDir when it is set. Every file is decoded with opts.Key and
+// the mode of opts.Spec/opts.Rules to get its original size and hash.
+func BuildHotUpdateManifest(folders string, opts *CCFolderOptions) (*CCHotUpdateManifest, error) {
+	if opts == nil || opts.HotUpdate == nil {
+		return nil, fmt.Errorf("BuildHotUpdateManifest[%v].opts.HotUpdate.nil", folders)
+	}
+	tasks, err := folderTasks("BuildHotUpdateManifest", folders, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hot := opts.HotUpdate
+	manifest := &CCHotUpdateManifest{
+		PackageURL:        hot.PackageURL,
+		RemoteManifestURL: hot.remoteURL(hot.RemoteManifestURL, ProjectManifest),
+		RemoteVersionURL:  hot.remoteURL(hot.RemoteVersionURL, VersionManifest),
+		Version:           hot.Version,
+		EngineVersion:     hot.EngineVersion,
+		Assets:            make(map[string]*CCHotUpdateAsset, len(tasks)),
+		SearchPaths:       hot.SearchPaths,
+	}
+
+	var lock = new(sync.Mutex)
+	runWorkers(len(tasks), opts.WorkerNum, func(idx int) {
+		t := tasks[idx]
+		asset, e := hotUpdateAsset(t.dst, opts.Key, opts.specFor(t.rel).Mode)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if e != nil {
+			log.Printf("BuildHotUpdateManifest[%v].err[%v]", t.dst, e)
+			err = e
+			return
+		}
+		manifest.Assets[t.rel] = asset
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// hotUpdateAsset .
+func hotUpdateAsset(filePath string, key string, compressMode byte) (*CCHotUpdateAsset, error) {
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("hotUpdateAsset[%v].ReadBinary.err[%v]", filePath, err)
+	}
+	header, dst, err := Verify(key, src, compressMode)
+	if err != nil {
+		return nil, fmt.Errorf("hotUpdateAsset[%v].err[%v]", filePath, err)
+	}
+	if header != nil {
+		compressMode = header.Mode()
+	}
+
+	sum := md5.Sum(src)
+	originSum := md5.Sum(dst)
+	return &CCHotUpdateAsset{
+		Size:       int64(len(src)),
+		MD5:        hex.EncodeToString(sum[:]),
+		OriginSize: int64(len(dst)),
+		OriginMD5:  hex.EncodeToString(originSum[:]),
+		Codec:      codecName(compressMode),
+	}, nil
+}
+
+// WriteHotUpdateManifest builds the manifest of folders and writes the
+// project.manifest and version.manifest into opts.HotUpdate.Dir.
+func WriteHotUpdateManifest(folders string, opts *CCFolderOptions) error {
+	manifest, err := BuildHotUpdateManifest(folders, opts)
+	if err != nil {
+		return err
+	}
+	dir := opts.HotUpdate.dir(folders, opts)
+
+	version := *manifest
+	version.Assets = nil
+	version.SearchPaths = nil
+	for name, m := range map[string]*CCHotUpdateManifest{ProjectManifest: manifest, VersionManifest: &version} {
+		src, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return fmt.Errorf("WriteHotUpdateManifest[%v].Marshal.err[%v]", name, err)
+		}
+		if _, err = ccutility.WriteBinary(filepath.Join(dir, name), src); err != nil {
+			return fmt.Errorf("WriteHotUpdateManifest[%v].err[%v]", name, err)
+		}
+	}
+	log.Printf("WriteHotUpdateManifest[%v].version[%v].assets[%v]", dir, manifest.Version, len(manifest.Assets))
+	return nil
+}
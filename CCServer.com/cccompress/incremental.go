@@ -0,0 +1,147 @@
+package cccompress
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// DefaultIncrementalManifest is kept in the output folder of an incremental run.
+const DefaultIncrementalManifest = ".ccmanifest.json"
+
+// CCIncrementalEntry .
+type CCIncrementalEntry struct {
+	Params string `json:"params"` // what the output was made with,see incrementalParams
+	Source string `json:"source"` // sha256 of the source file
+	Output string `json:"output"` // sha256 of the output file
+	Size   int64  `json:"size"`   // size of the output file
+}
+
+// CCIncrementalManifest maps source paths (relative to the source folder) to
+// the hashes of the source and of the output made from it.
+type CCIncrementalManifest struct {
+	Files map[string]*CCIncrementalEntry `json:"files"`
+
+	path string
+	lock sync.Mutex
+}
+
+// LoadIncrementalManifest .
+func LoadIncrementalManifest(manifestPath string) (*CCIncrementalManifest, error) {
+	p := &CCIncrementalManifest{
+		Files: make(map[string]*CCIncrementalEntry),
+		path:  manifestPath,
+	}
+
+	src, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LoadIncrementalManifest[%v].ReadFile.err[%v]", manifestPath, err)
+	}
+	if err = json.Unmarshal(src, p); err != nil {
+		return nil, fmt.Errorf("LoadIncrementalManifest[%v].Unmarshal.err[%v]", manifestPath, err)
+	}
+	if p.Files == nil {
+		p.Files = make(map[string]*CCIncrementalEntry)
+	}
+	return p, nil
+}
+
+// codecParams names the codecs opts gives the files of a job.
+func codecParams(name string, opts *CCFolderOptions) string {
+	spec := opts.spec()
+	params := spec.String()
+	for i := range opts.Rules {
+		params += "," + opts.Rules[i].String()
+	}
+	return fmt.Sprintf("%v.%v", name, params)
+}
+
+// incrementalParams changes whenever the same source would give another output,
+// it also names the job of a journal.
+func incrementalParams(name string, opts *CCFolderOptions) string {
+	return fmt.Sprintf("%v.%v", codecParams(name, opts), ccutility.HashBinary([]byte(opts.Key)))
+}
+
+// Unchanged reports whether the output of rel is up to date. srcHash is the
+// current hash of the source,to be passed on to Update.
+func (p *CCIncrementalManifest) Unchanged(rel string, params string, srcPath string, dstPath string) (srcHash string, ok bool) {
+	srcHash, _, err := ccutility.HashFile(srcPath)
+	if err != nil {
+		return "", false
+	}
+
+	p.lock.Lock()
+	e := p.Files[rel]
+	p.lock.Unlock()
+	if e == nil || e.Params != params || e.Source != srcHash {
+		return srcHash, false
+	}
+
+	// the output may have been touched or removed since
+	dstHash, size, err := ccutility.HashFile(dstPath)
+	if err != nil || size != e.Size || dstHash != e.Output {
+		return srcHash, false
+	}
+	return srcHash, true
+}
+
+// Update records the output just made for rel.
+func (p *CCIncrementalManifest) Update(rel string, params string, srcHash string, dstPath string) error {
+	dstHash, size, err := ccutility.HashFile(dstPath)
+	if err != nil {
+		return fmt.Errorf("CCIncrementalManifest.Update[%v].err[%v]", rel, err)
+	}
+
+	p.lock.Lock()
+	p.Files[rel] = &CCIncrementalEntry{
+		Params: params,
+		Source: srcHash,
+		Output: dstHash,
+		Size:   size,
+	}
+	p.lock.Unlock()
+	return nil
+}
+
+// Prune removes the outputs of sources gone from folders. A source still
+// there but no longer matching the filter keeps its output.
+func (p *CCIncrementalManifest) Prune(folders string, outDir string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var err error
+	for rel := range p.Files {
+		if _, e := os.Lstat(filepath.Join(folders, filepath.FromSlash(rel))); !os.IsNotExist(e) {
+			continue
+		}
+		dst := filepath.Join(outDir, filepath.FromSlash(rel))
+		if e := os.Remove(dst); e != nil && !os.IsNotExist(e) {
+			err = fmt.Errorf("CCIncrementalManifest.Prune[%v].err[%v]", dst, e)
+			continue
+		}
+		log.Printf("CCIncrementalManifest.Prune[%v]", dst)
+		delete(p.Files, rel)
+	}
+	return err
+}
+
+// Save .
+func (p *CCIncrementalManifest) Save() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	src, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("CCIncrementalManifest.Save[%v].Marshal.err[%v]", p.path, err)
+	}
+	_, err = ccutility.WriteBinary(p.path, src)
+	return err
+}
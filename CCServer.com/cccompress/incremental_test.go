@@ -0,0 +1,139 @@
+package cccompress
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"CCServer.com/ccutility"
+)
+
+func TestCompressFoldersIncremental(t *testing.T) {
+	files := map[string]string{"a.txt": "first file", "sub/b.txt": "second file", "c.png": "third file"}
+
+	tests := []struct {
+		name    string
+		key     string
+		change  func(t *testing.T, src string, out string)
+		filter  *ccutility.FileFilter
+		written []string // outputs made again by the second run
+		gone    []string // outputs removed by the second run
+	}{
+		{"unchanged", "xxx.yyy", nil, nil, nil, nil},
+		{"source changed", "xxx.yyy", func(t *testing.T, src string, out string) {
+			writeTestFile(t, filepath.Join(src, "a.txt"), "first file,edited")
+		}, nil, []string{"a.txt"}, nil},
+		{"source added", "xxx.yyy", func(t *testing.T, src string, out string) {
+			writeTestFile(t, filepath.Join(src, "sub/d.txt"), "new file")
+		}, nil, []string{"sub/d.txt"}, nil},
+		{"source removed", "xxx.yyy", func(t *testing.T, src string, out string) {
+			os.Remove(filepath.Join(src, "sub", "b.txt"))
+		}, nil, nil, []string{"sub/b.txt"}},
+		{"output removed", "xxx.yyy", func(t *testing.T, src string, out string) {
+			os.Remove(filepath.Join(out, "c.png"))
+		}, nil, []string{"c.png"}, nil},
+		{"output edited", "xxx.yyy", func(t *testing.T, src string, out string) {
+			writeTestFile(t, filepath.Join(out, "a.txt"), "edited")
+		}, nil, []string{"a.txt"}, nil},
+		{"other key", "zzz.www", nil, nil, []string{"a.txt", "sub/b.txt", "c.png"}, nil},
+		// a source no longer selected keeps its output
+		{"filtered out", "xxx.yyy", nil, ccutility.NewFileFilter("txt"), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+			for name, data := range files {
+				writeTestFile(t, filepath.Join(src, filepath.FromSlash(name)), data)
+			}
+
+			opts := NewFolderOptions("", "xxx.yyy", GZip, false, 2)
+			opts.OutDir = out
+			opts.Incremental = true
+			if n, err := CompressFoldersWithOptions(src, opts); err != nil || n != int64(len(files)) {
+				t.Fatalf("CompressFolders[%v].err[%v]", n, err)
+			}
+			before := map[string]os.FileInfo{}
+			for name := range files {
+				fi, err := os.Stat(filepath.Join(out, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				before[name] = fi
+			}
+
+			if tt.change != nil {
+				tt.change(t, src, out)
+			}
+			// a removed output may come back with the same inode,it only has to exist
+			for name := range before {
+				if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); os.IsNotExist(err) {
+					delete(before, name)
+				}
+			}
+			opts = NewFolderOptions("", tt.key, GZip, false, 2)
+			opts.OutDir = out
+			opts.Incremental = true
+			opts.Filter = tt.filter
+			if _, err := CompressFoldersWithOptions(src, opts); err != nil {
+				t.Fatalf("CompressFolders.err[%v]", err)
+			}
+
+			written := map[string]bool{}
+			for _, name := range tt.written {
+				written[name] = true
+			}
+			gone := map[string]bool{}
+			for _, name := range tt.gone {
+				gone[name] = true
+			}
+			for name, fi := range before {
+				after, err := os.Stat(filepath.Join(out, filepath.FromSlash(name)))
+				if gone[name] {
+					if !os.IsNotExist(err) {
+						t.Errorf("output[%v].not pruned", name)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("output[%v].err[%v]", name, err)
+					continue
+				}
+				// outputs are written through a rename,a new file means a new output
+				if got := !os.SameFile(fi, after); got != written[name] {
+					t.Errorf("output[%v].written[%v/%v]", name, got, written[name])
+				}
+			}
+			for _, name := range tt.written {
+				if _, ok := before[name]; !ok {
+					if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err != nil {
+						t.Errorf("output[%v].err[%v]", name, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompressFoldersIncrementalNested(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	writeTestFile(t, filepath.Join(dir, "a.txt"), "source")
+
+	opts := NewFolderOptions("", "xxx.yyy", GZip, false, 1)
+	opts.Incremental = true
+	if _, err := CompressFoldersWithOptions(dir, opts); err == nil {
+		t.Errorf("CompressFolders.Incremental without OutDir.err.nil")
+	}
+
+	// an output folder inside the source isn't compressed again
+	opts.OutDir = out
+	for i := 0; i < 2; i++ {
+		if n, err := CompressFoldersWithOptions(dir, opts); err != nil || n != 1 {
+			t.Fatalf("run[%v].CompressFolders[%v].err[%v]", i, n, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "out")); !os.IsNotExist(err) {
+		t.Errorf("CompressFolders.output folder compressed[%v]", err)
+	}
+}
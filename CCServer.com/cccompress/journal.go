@@ -0,0 +1,162 @@
+package cccompress
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// CCJournal records the files a folder job has finished, one line per file
+// after a header line naming the job:
+//
+//	#\t<params>
+//	<sha256>\t<size>\t<absolute path>
+//
+// The size and hash describe the file as it was left by the job, so a re-run
+// skips a file only if it still looks exactly like that.
+type CCJournal struct {
+	path   string
+	params string
+	file   *os.File
+	lock   sync.Mutex
+	done   map[string]journalEntry
+}
+
+// journalHeader starts the header line
+const journalHeader = "#\t"
+
+type journalEntry struct {
+	size int64
+	hash string
+}
+
+// OpenJournal loads an existing journal (if any) and opens it for appending.
+// params names the job (operation,codec and key hash),a journal written by
+// another job is refused rather than taken as done.
+func OpenJournal(journalPath string, params string) (*CCJournal, error) {
+	p := &CCJournal{
+		path:   journalPath,
+		params: params,
+		done:   make(map[string]journalEntry),
+	}
+
+	header, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(journalPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("OpenJournal[%v].OpenFile.err[%v]", journalPath, err)
+	}
+	if !header {
+		if _, err = fmt.Fprintf(f, "%v%v\n", journalHeader, params); err == nil {
+			err = f.Sync()
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("OpenJournal[%v].Write.err[%v]", journalPath, err)
+		}
+	}
+	p.file = f
+	return p, nil
+}
+
+// load reads the journal,header is false when it is missing or empty.
+func (p *CCJournal) load() (header bool, err error) {
+	f, err := os.Open(p.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("OpenJournal[%v].Open.err[%v]", p.path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		if err = scanner.Err(); err != nil {
+			return false, fmt.Errorf("OpenJournal[%v].Scan.err[%v]", p.path, err)
+		}
+		return false, nil
+	}
+	if params := strings.TrimPrefix(scanner.Text(), journalHeader); params != p.params {
+		return false, fmt.Errorf("OpenJournal[%v].job[%v/%v].no match", p.path, params, p.params)
+	}
+	for scanner.Scan() {
+		// a torn last line after a crash is simply ignored
+		a := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(a) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(a[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		p.done[a[2]] = journalEntry{size: size, hash: a[0]}
+	}
+	if err = scanner.Err(); err != nil {
+		return false, fmt.Errorf("OpenJournal[%v].Scan.err[%v]", p.path, err)
+	}
+	return true, nil
+}
+
+// IsDone .
+func (p *CCJournal) IsDone(filePath string) bool {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+
+	p.lock.Lock()
+	e, ok := p.done[abs]
+	p.lock.Unlock()
+	if !ok {
+		return false
+	}
+
+	fi, err := os.Stat(abs)
+	if err != nil || fi.Size() != e.size {
+		return false
+	}
+	hash, _, err := ccutility.HashFile(abs)
+	return err == nil && hash == e.hash
+}
+
+// Done records filePath as finished and flushes the journal to disk.
+func (p *CCJournal) Done(filePath string) error {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("CCJournal.Done[%v].Abs.err[%v]", filePath, err)
+	}
+	hash, size, err := ccutility.HashFile(abs)
+	if err != nil {
+		return fmt.Errorf("CCJournal.Done[%v].err[%v]", filePath, err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, err = fmt.Fprintf(p.file, "%v\t%v\t%v\n", hash, size, abs); err != nil {
+		return fmt.Errorf("CCJournal.Done[%v].Write.err[%v]", filePath, err)
+	}
+	if err = p.file.Sync(); err != nil {
+		return fmt.Errorf("CCJournal.Done[%v].Sync.err[%v]", filePath, err)
+	}
+	p.done[abs] = journalEntry{size: size, hash: hash}
+	return nil
+}
+
+// Close .
+func (p *CCJournal) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.file.Close()
+}
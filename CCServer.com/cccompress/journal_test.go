@@ -0,0 +1,145 @@
+package cccompress
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, filePath string, journalPath string)
+		done   bool
+	}{
+		{"unchanged", func(t *testing.T, filePath string, journalPath string) {}, true},
+		{"same size", func(t *testing.T, filePath string, journalPath string) {
+			writeTestFile(t, filePath, "HELLO")
+		}, false},
+		{"other size", func(t *testing.T, filePath string, journalPath string) {
+			writeTestFile(t, filePath, "hello world")
+		}, false},
+		{"removed", func(t *testing.T, filePath string, journalPath string) {
+			os.Remove(filePath)
+		}, false},
+		{"torn last line", func(t *testing.T, filePath string, journalPath string) {
+			f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString("abc\t12")
+			f.Close()
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, "a.txt")
+			journalPath := filepath.Join(dir, "job.journal")
+			writeTestFile(t, filePath, "hello")
+
+			j, err := OpenJournal(journalPath, "job")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j.IsDone(filePath) {
+				t.Errorf("IsDone.before Done")
+			}
+			if err = j.Done(filePath); err != nil {
+				t.Fatal(err)
+			}
+			if err = j.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, filePath, journalPath)
+			if j, err = OpenJournal(journalPath, "job"); err != nil {
+				t.Fatal(err)
+			}
+			defer j.Close()
+			if got := j.IsDone(filePath); got != tt.done {
+				t.Errorf("IsDone[%v/%v]", got, tt.done)
+			}
+		})
+	}
+}
+
+func TestJournalOtherJob(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "job.journal")
+	j, err := OpenJournal(journalPath, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	if j, err = OpenJournal(journalPath, "other job"); err == nil {
+		j.Close()
+		t.Errorf("OpenJournal.other job.err.nil")
+	}
+}
+
+func TestCompressFoldersJournal(t *testing.T) {
+	files := map[string]string{"a.txt": "hello journal", "sub/b.txt": "resumed job"}
+
+	tests := []struct {
+		name string
+		key  string
+		mode int
+		ok   bool // whether the second run may use the journal
+	}{
+		{"resume", "xxx.yyy", GZip, true},
+		{"other key", "zzz.www", GZip, false},
+		{"other codec", "xxx.yyy", Zstd, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			for name, data := range files {
+				writeTestFile(t, filepath.Join(src, filepath.FromSlash(name)), data)
+			}
+			journalPath := filepath.Join(dir, "job.journal")
+
+			opts := NewFolderOptions("", "xxx.yyy", GZip, true, 2)
+			opts.Journal = journalPath
+			if n, err := CompressFoldersWithOptions(src, opts); err != nil || n != int64(len(files)) {
+				t.Fatalf("CompressFolders[%v].err[%v]", n, err)
+			}
+
+			opts = NewFolderOptions("", tt.key, tt.mode, true, 2)
+			opts.Journal = journalPath
+			n, err := CompressFoldersWithOptions(src, opts)
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("CompressFolders.other job.err.nil")
+				}
+				return
+			}
+			if err != nil || n != int64(len(files)) {
+				t.Fatalf("CompressFolders[%v].err[%v]", n, err)
+			}
+
+			// done files were skipped,not compressed twice
+			for name, data := range files {
+				b, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, got, err := Decompress("xxx.yyy", b, GZip)
+				if err != nil || string(got) != data {
+					t.Errorf("Decompress[%v].got[%q].err[%v]", name, got, err)
+				}
+			}
+		})
+	}
+}
+
+// writeTestFile .
+func writeTestFile(t *testing.T, filePath string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
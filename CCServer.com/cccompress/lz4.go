@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/pierrec/lz4"
+	"io"
 	"io/ioutil"
 )
 
@@ -45,6 +46,25 @@ func (p *CCLz4) Decompress(in []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+// NewWriter .
+func (p *CCLz4) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	writer := lz4.NewWriter(w)
+	if writer == nil {
+		return nil, fmt.Errorf("CCLz4.NewWriter.nil")
+	}
+	writer.Header.CompressionLevel = p.CompressionLevel
+	return writer, nil
+}
+
+// NewReader .
+func (p *CCLz4) NewReader(r io.Reader) (io.ReadCloser, error) {
+	reader := lz4.NewReader(r)
+	if reader == nil {
+		return nil, fmt.Errorf("CCLz4.NewReader.nil")
+	}
+	return ioutil.NopCloser(reader), nil
+}
+
 // NewLz4 .
 func NewLz4() *CCLz4 {
 	return &CCLz4{
@@ -54,3 +74,16 @@ func NewLz4() *CCLz4 {
 
 // DefaultLz4 .
 var DefaultLz4 = NewLz4()
+
+func init() {
+	// 0 is the fast compressor,above it is the search depth of the HC one
+	RegisterCodec(&CCCodecInfo{
+		Mode:     Lz4,
+		Name:     "lz4",
+		Default:  func() Codec { return DefaultLz4 },
+		New:      func(level int) Codec { return &CCLz4{CompressionLevel: level} },
+		MinLevel: 0,
+		MaxLevel: 1 << 16,
+		Levels:   levelNames(0, 9, 1<<16),
+	})
+}
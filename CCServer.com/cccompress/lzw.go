@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/lzw"
 	"fmt"
+	"io"
 	"io/ioutil"
 )
 
@@ -43,6 +44,16 @@ func (p *CCLzw) Decompress(in []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+// NewWriter .
+func (p *CCLzw) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return lzw.NewWriter(w, p.Order, p.ListWidth), nil
+}
+
+// NewReader .
+func (p *CCLzw) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return lzw.NewReader(r, p.Order, p.ListWidth), nil
+}
+
 // NewLzw .
 func NewLzw() *CCLzw {
 	return &CCLzw{
@@ -53,3 +64,11 @@ func NewLzw() *CCLzw {
 
 // DefaultLzw .
 var DefaultLzw = NewLzw()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:    Lzw,
+		Name:    "lzw",
+		Default: func() Codec { return DefaultLzw },
+	})
+}
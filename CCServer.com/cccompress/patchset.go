@@ -0,0 +1,264 @@
+package cccompress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"CCServer.com/ccutility"
+)
+
+// PatchIndexEntry is the entry of a patch set listing its changes.
+const PatchIndexEntry = ".ccpatch.json"
+
+// patch set ops,an "add" or "delta" file is the entry Op/Path
+const (
+	PatchAdd    = "add"
+	PatchDelta  = "delta"
+	PatchRemove = "remove"
+)
+
+// CCPatchFile is a change of a patch set.
+type CCPatchFile struct {
+	Path     string `json:"path"`
+	Op       string `json:"op"`
+	OldCRC32 uint32 `json:"old_crc32,omitempty"` // of the file a delta applies to
+	CRC32    uint32 `json:"crc32,omitempty"`     // of the new file
+	Size     int64  `json:"size,omitempty"`      // of the new file
+}
+
+// CCPatchIndex .
+type CCPatchIndex struct {
+	Files []*CCPatchFile `json:"files"`
+}
+
+// listFolder maps the slash separated relative paths of the matching files
+// under folders to their paths.
+func listFolder(folders string, opts *CCFolderOptions) (map[string]string, error) {
+	filter := opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(opts.Ext)
+	}
+	var allFile []string
+	allFile, err := ccutility.GetAllFileByFilter(folders, filter, allFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(allFile))
+	for _, f := range allFile {
+		rel, err := filepath.Rel(folders, f)
+		if err != nil {
+			return nil, fmt.Errorf("listFolder[%v].Rel.err[%v]", f, err)
+		}
+		ret[filepath.ToSlash(rel)] = f
+	}
+	return ret, nil
+}
+
+// DiffFolders writes to patchPath the patch set turning oldDir into newDir:
+// a CC archive holding a delta of every changed file,the whole new files,and
+// an index also listing the removed ones. Entries are compressed with the
+// codec of opts.Spec/opts.Rules and obfuscated with opts.Key,opts.Filter
+// selects the files on both sides. successed is the number of changes.
+func DiffFolders(oldDir string, newDir string, patchPath string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("DiffFolders[%v].opts.nil", newDir)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("DiffFolders[%v].err[%v]", newDir, err)
+	}
+	oldFiles, err := listFolder(oldDir, opts)
+	if err != nil {
+		return 0, err
+	}
+	newFiles, err := listFolder(newDir, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := ccutility.CreateAtomic(patchPath)
+	if err != nil {
+		return 0, fmt.Errorf("DiffFolders[%v].err[%v]", patchPath, err)
+	}
+	w, err := NewArchiveWriter(out.File, opts.Key)
+	if err != nil {
+		out.Abort()
+		return 0, err
+	}
+
+	index, err := diffFolders(w, oldFiles, newFiles, opts)
+	if err == nil {
+		var src []byte
+		if src, err = json.MarshalIndent(index, "", "  "); err == nil {
+			err = w.Add(PatchIndexEntry, src, CodecSpec{Mode: GZip}, nil)
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		out.Abort()
+		return 0, fmt.Errorf("DiffFolders[%v].err[%v]", patchPath, err)
+	}
+	if err = out.Commit(""); err != nil {
+		return 0, fmt.Errorf("DiffFolders[%v].err[%v]", patchPath, err)
+	}
+	return int64(len(index.Files)), nil
+}
+
+// diffFolders adds the changed files to w and returns the index.
+func diffFolders(w *CCArchiveWriter, oldFiles map[string]string, newFiles map[string]string, opts *CCFolderOptions) (*CCPatchIndex, error) {
+	names := make([]string, 0, len(newFiles))
+	for rel := range newFiles {
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+
+	index := &CCPatchIndex{}
+	for _, rel := range names {
+		fi, err := os.Stat(newFiles[rel])
+		if err != nil {
+			return nil, fmt.Errorf("diffFolders[%v].Stat.err[%v]", newFiles[rel], err)
+		}
+		src, err := ccutility.ReadBinary(newFiles[rel])
+		if err != nil {
+			return nil, fmt.Errorf("diffFolders[%v].ReadBinary.err[%v]", newFiles[rel], err)
+		}
+		file := &CCPatchFile{Path: rel, Op: PatchAdd, CRC32: crc32.ChecksumIEEE(src), Size: int64(len(src))}
+
+		entry := src
+		if oldPath, ok := oldFiles[rel]; ok {
+			old, err := ccutility.ReadBinary(oldPath)
+			if err != nil {
+				return nil, fmt.Errorf("diffFolders[%v].ReadBinary.err[%v]", oldPath, err)
+			}
+			if bytes.Equal(old, src) {
+				continue
+			}
+			// a delta larger than the file isn't worth it
+			if delta := Diff(old, src, 0); len(delta) < len(src) {
+				file.Op = PatchDelta
+				file.OldCRC32 = crc32.ChecksumIEEE(old)
+				entry = delta
+			}
+		}
+		if err = w.Add(file.Op+"/"+rel, entry, opts.specFor(rel), fi); err != nil {
+			return nil, err
+		}
+		index.Files = append(index.Files, file)
+	}
+
+	var removed []string
+	for rel := range oldFiles {
+		if _, ok := newFiles[rel]; !ok {
+			removed = append(removed, rel)
+		}
+	}
+	sort.Strings(removed)
+	for _, rel := range removed {
+		index.Files = append(index.Files, &CCPatchFile{Path: rel, Op: PatchRemove})
+	}
+	return index, nil
+}
+
+// ReadPatchIndex .
+func ReadPatchIndex(r *CCArchiveReader) (*CCPatchIndex, error) {
+	src, err := r.ReadFile(PatchIndexEntry)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPatchIndex.err[%v]", err)
+	}
+	index := &CCPatchIndex{}
+	if err = json.Unmarshal(src, index); err != nil {
+		return nil, fmt.Errorf("ReadPatchIndex.Unmarshal.err[%v]", err)
+	}
+	return index, nil
+}
+
+// patchResult is a file ready to be written.
+type patchResult struct {
+	file  *CCPatchFile
+	rel   string // Path once checked by cleanEntryName
+	entry *CCArchiveEntry
+	data  []byte
+}
+
+// PatchFolder applies the patch set at patchPath to folders in place. Every
+// change is checked first,so nothing is written when a file isn't the one
+// the patch set was made from. successed is the number of changes.
+func PatchFolder(folders string, patchPath string, key string) (successed int64, err error) {
+	r, err := OpenArchive(patchPath, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	index, err := ReadPatchIndex(r)
+	if err != nil {
+		return 0, fmt.Errorf("PatchFolder[%v].err[%v]", patchPath, err)
+	}
+
+	results := make([]*patchResult, 0, len(index.Files))
+	for _, file := range index.Files {
+		rel, err := cleanEntryName(file.Path)
+		if err != nil {
+			return 0, fmt.Errorf("PatchFolder[%v].err[%v]", patchPath, err)
+		}
+		filePath := filepath.Join(folders, filepath.FromSlash(rel))
+		if file.Op == PatchRemove {
+			results = append(results, &patchResult{file: file, rel: rel})
+			continue
+		}
+		if file.Op != PatchAdd && file.Op != PatchDelta {
+			return 0, fmt.Errorf("PatchFolder[%v].op[%v].unknown", file.Path, file.Op)
+		}
+
+		e := r.Entry(file.Op + "/" + rel)
+		if e == nil {
+			return 0, fmt.Errorf("PatchFolder[%v].entry[%v/%v].not found", patchPath, file.Op, rel)
+		}
+		data, err := r.ReadEntry(e)
+		if err != nil {
+			return 0, fmt.Errorf("PatchFolder[%v].err[%v]", patchPath, err)
+		}
+		if file.Op == PatchDelta {
+			old, err := ccutility.ReadBinary(filePath)
+			if err != nil {
+				return 0, fmt.Errorf("PatchFolder[%v].ReadBinary.err[%v]", filePath, err)
+			}
+			if crc32.ChecksumIEEE(old) != file.OldCRC32 {
+				return 0, fmt.Errorf("PatchFolder[%v].old file.no match", filePath)
+			}
+			if data, err = ApplyDelta(old, data); err != nil {
+				return 0, fmt.Errorf("PatchFolder[%v].err[%v]", filePath, err)
+			}
+		}
+		if crc32.ChecksumIEEE(data) != file.CRC32 {
+			return 0, fmt.Errorf("PatchFolder[%v].crc32.no match", filePath)
+		}
+		results = append(results, &patchResult{file: file, rel: rel, entry: e, data: data})
+	}
+
+	for _, ret := range results {
+		filePath := filepath.Join(folders, filepath.FromSlash(ret.rel))
+		if ret.file.Op == PatchRemove {
+			if e := os.Remove(filePath); e != nil && !os.IsNotExist(e) {
+				return successed, fmt.Errorf("PatchFolder[%v].Remove.err[%v]", filePath, e)
+			}
+			successed++
+			continue
+		}
+		if _, err = ccutility.WriteBinary(filePath, ret.data); err != nil {
+			return successed, fmt.Errorf("PatchFolder[%v].err[%v]", filePath, err)
+		}
+		if err = ccutility.ApplyFileInfo(filePath, ret.entry.FileMode.Perm(), ret.entry.ModTime); err != nil {
+			return successed, fmt.Errorf("PatchFolder[%v].err[%v]", filePath, err)
+		}
+		successed++
+	}
+	return successed, nil
+}
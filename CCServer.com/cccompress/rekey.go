@@ -0,0 +1,89 @@
+package cccompress
+
+import (
+	"fmt"
+	"os"
+
+	"CCServer.com/ccutility"
+)
+
+// Rekey changes the obfuscation key of a CC file without recompressing it.
+// The result is decoded once with newKey so that a wrong oldKey is caught,
+// which takes the CRC32 extension or a codec checking its own data: an
+// Uncompressed or Lzw body without CRC32 decodes with any key and is refused.
+func Rekey(oldKey string, newKey string, src []byte) ([]byte, error) {
+	a, ok := splitKey(oldKey)
+	if !ok {
+		return nil, fmt.Errorf("Rekey[%v].old key invalid", oldKey)
+	}
+	b, ok := splitKey(newKey)
+	if !ok {
+		return nil, fmt.Errorf("Rekey[%v].new key invalid", newKey)
+	}
+
+	header, err := getHeader(src)
+	if err != nil {
+		return nil, fmt.Errorf("Rekey.err[%v]", err)
+	}
+
+	if _, ok := header.CRC32(); !ok {
+		switch header.Mode() {
+		case Uncompressed, Lzw:
+			return nil, fmt.Errorf("Rekey.mode[%v].no crc32,a wrong old key couldn't be told", codecName(header.Mode()))
+		}
+	}
+
+	dst := make([]byte, len(src))
+	copy(dst, src)
+	body := dst[header.Size():]
+	obfuscate(body, a)
+	obfuscate(body, b)
+
+	check := make([]byte, len(dst))
+	copy(check, dst)
+	if _, _, err = Decompress(newKey, check, header.CompressMode[0]); err != nil {
+		return nil, fmt.Errorf("Rekey.wrong old key?err[%v]", err)
+	}
+	return dst, nil
+}
+
+// RekeyFile .
+func RekeyFile(filePath string, oldKey string, newKey string, bOverWrite bool) (dlen int64, err error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("RekeyFile[%v].Stat.err[%v]", filePath, err)
+	}
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("RekeyFile[%v].ReadBinary.err[%v]", filePath, err)
+	}
+	dst, err := Rekey(oldKey, newKey, src)
+	if err != nil {
+		return 0, fmt.Errorf("RekeyFile[%v].err[%v]", filePath, err)
+	}
+	dlen, err = ccutility.WriteBinaryWithBackup(filePath, dst, backupPath(filePath, bOverWrite))
+	if err != nil {
+		return 0, err
+	}
+	if err = ccutility.CopyFileInfo(filePath, fi); err != nil {
+		return 0, fmt.Errorf("RekeyFile[%v].err[%v]", filePath, err)
+	}
+	return dlen, nil
+}
+
+// RekeyFolders rekeys every file under folders from opts.Key to newKey.
+func RekeyFolders(folders string, newKey string, opts *CCFolderOptions) (successed int64, err error) {
+	return processFolders("RekeyFolders", folders, opts, notRekeyable, func(t *folderTask) error {
+		_, err := RekeyFile(t.src, opts.Key, newKey, opts.OverWrite)
+		return err
+	})
+}
+
+// notRekeyable .
+func notRekeyable(t *folderTask, opts *CCFolderOptions) string {
+	_, header, _, err := SniffFile(t.src)
+	if err == nil && !header {
+		return "no cc header"
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package cccompress
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// CCCodecRule gives the codec of the files matching Ext or Pattern.
+type CCCodecRule struct {
+	Ext     string // suffix,case insensitive e.g. ".json"
+	Pattern string // doublestar glob on the slash separated relative path e.g. "ui/**/*.png"
+	Spec    CodecSpec
+}
+
+// ParseCodecRule parses "match=spec" e.g. ".json=zstd:19","ui/**/*.png=none".
+// match is a glob when it holds any of *?[{/ ,an extension otherwise.
+func ParseCodecRule(s string) (CCCodecRule, error) {
+	var rule CCCodecRule
+	i := strings.LastIndex(s, "=")
+	if i <= 0 {
+		return rule, fmt.Errorf("ParseCodecRule[%v].want match=spec", s)
+	}
+	match := strings.TrimSpace(s[:i])
+	spec, err := ParseCodecSpec(s[i+1:])
+	if err != nil {
+		return rule, fmt.Errorf("ParseCodecRule[%v].err[%v]", s, err)
+	}
+	rule.Spec = spec
+	if strings.ContainsAny(match, "*?[{/") {
+		rule.Pattern = match
+	} else {
+		rule.Ext = match
+	}
+	return rule, rule.Validate()
+}
+
+// Validate .
+func (p *CCCodecRule) Validate() error {
+	if len(p.Ext) == 0 && len(p.Pattern) == 0 {
+		return fmt.Errorf("CCCodecRule[%v].no ext nor pattern", p.String())
+	}
+	if len(p.Pattern) > 0 && !doublestar.ValidatePattern(p.Pattern) {
+		return fmt.Errorf("CCCodecRule.pattern[%v].invalid", p.Pattern)
+	}
+	if CodecByMode(p.Spec.Mode) == nil {
+		return fmt.Errorf("CCCodecRule[%v].unknown mode", p.String())
+	}
+	return nil
+}
+
+// Match reports whether the file at rel,relative to the folder,matches.
+// With both Ext and Pattern both must match.
+func (p *CCCodecRule) Match(rel string) bool {
+	if len(p.Ext) > 0 && !strings.HasSuffix(strings.ToLower(rel), strings.ToLower(p.Ext)) {
+		return false
+	}
+	if len(p.Pattern) > 0 {
+		ok, _ := doublestar.Match(p.Pattern, rel)
+		return ok
+	}
+	return len(p.Ext) > 0
+}
+
+// String .
+func (p *CCCodecRule) String() string {
+	match := p.Ext
+	if len(p.Pattern) > 0 {
+		match = p.Pattern
+		if len(p.Ext) > 0 {
+			match += "(" + p.Ext + ")"
+		}
+	}
+	return match + "=" + p.Spec.String()
+}
+
+// specFor is the codec spec of the file at rel.
+func (p *CCFolderOptions) specFor(rel string) CodecSpec {
+	for i := range p.Rules {
+		if p.Rules[i].Match(rel) {
+			return p.Rules[i].Spec
+		}
+	}
+	return p.spec()
+}
+
+// validateRules .
+func (p *CCFolderOptions) validateRules() error {
+	for i := range p.Rules {
+		if err := p.Rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cccompress
+
+import (
+	"testing"
+)
+
+func TestParseCodecRule(t *testing.T) {
+	tests := []struct {
+		s    string
+		want CCCodecRule
+		ok   bool
+	}{
+		{".json=zstd:19", CCCodecRule{Ext: ".json", Spec: CodecSpec{Mode: Zstd, Level: 19, HasLevel: true}}, true},
+		{"png=none", CCCodecRule{Ext: "png", Spec: CodecSpec{Mode: Uncompressed}}, true},
+		{"ui/**/*.png=gzip", CCCodecRule{Pattern: "ui/**/*.png", Spec: CodecSpec{Mode: GZip}}, true},
+		{"*.txt=br", CCCodecRule{Pattern: "*.txt", Spec: CodecSpec{Mode: Brotli}}, true},
+		{"{a,b}.bin=zlib", CCCodecRule{Pattern: "{a,b}.bin", Spec: CodecSpec{Mode: Zlib}}, true},
+		{" .js = gzip:9", CCCodecRule{Ext: ".js", Spec: CodecSpec{Mode: GZip, Level: 9, HasLevel: true}}, true},
+		{".json", CCCodecRule{}, false},
+		{"=zstd", CCCodecRule{}, false},
+		{".json=", CCCodecRule{}, false},
+		{".json=nosuchcodec", CCCodecRule{}, false},
+		{".json=zstd:99", CCCodecRule{}, false},
+		{"[a=gzip", CCCodecRule{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseCodecRule(tt.s)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseCodecRule.err[%v]", err)
+			}
+			if tt.ok && got != tt.want {
+				t.Errorf("ParseCodecRule[%+v/%+v]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecFor(t *testing.T) {
+	rules := []CCCodecRule{
+		{Pattern: "raw/**", Spec: CodecSpec{Mode: Uncompressed}},
+		{Ext: ".JSON", Spec: CodecSpec{Mode: Zstd, Level: 19, HasLevel: true}},
+		{Pattern: "ui/**/*.png", Ext: ".png", Spec: CodecSpec{Mode: Brotli}},
+		{Ext: ".json", Spec: CodecSpec{Mode: Zlib}},
+	}
+	opts := NewFolderOptions("", "", GZip, false, 1)
+	opts.Rules = rules
+	if err := opts.validateRules(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel  string
+		want CodecSpec
+	}{
+		{"a.json", rules[1].Spec},
+		{"deep/b.Json", rules[1].Spec},
+		{"raw/c.json", rules[0].Spec}, // the first matching rule wins
+		{"ui/icons/d.png", rules[2].Spec},
+		{"e.png", CodecSpec{Mode: GZip}},
+		{"ui/f.txt", CodecSpec{Mode: GZip}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := opts.specFor(tt.rel); got != tt.want {
+				t.Errorf("specFor[%+v/%+v]", got, tt.want)
+			}
+		})
+	}
+
+	opts.Rules = append(opts.Rules, CCCodecRule{Spec: CodecSpec{Mode: GZip}})
+	if err := opts.validateRules(); err == nil {
+		t.Errorf("validateRules.rule without match.err.nil")
+	}
+}
@@ -0,0 +1,116 @@
+package cccompress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+)
+
+// sniffLen is enough to hold a CC header and every magic number below
+const sniffLen = 64
+
+// zlibSniffLimit bounds what isZlib inflates
+const zlibSniffLimit = 64 << 10
+
+var (
+	magicGZip  = []byte{0x1F, 0x8B, 0x08}
+	magicBz2   = []byte{'B', 'Z', 'h'}
+	magicLz4   = []byte{0x04, 0x22, 0x4D, 0x18}
+	magicZstd  = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	magicBlock = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59} // bzip2 block / end of stream
+	magicEos   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// Sniff reports whether src is already compressed.
+// header is true if src starts with a valid CC header,mode is then the mode in
+// the header; otherwise mode is the codec recognised by its magic number.
+// ok is false when src looks like plain data.
+// Lzw streams have no magic number and are never recognised.
+func Sniff(src []byte) (mode byte, header bool, ok bool) {
+	return sniff(src, int64(len(src)))
+}
+
+// SniffFile is Sniff over the first bytes of a file.
+func SniffFile(filePath string) (mode byte, header bool, ok bool, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, false, false, fmt.Errorf("SniffFile[%v].Open.err[%v]", filePath, err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, false, false, fmt.Errorf("SniffFile[%v].Stat.err[%v]", filePath, err)
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, false, false, fmt.Errorf("SniffFile[%v].Read.err[%v]", filePath, err)
+	}
+
+	mode, header, ok = sniff(buf[:n], fi.Size())
+	return mode, header, ok, nil
+}
+
+// sniff .
+func sniff(src []byte, total int64) (mode byte, header bool, ok bool) {
+	if h, err := parseHeader(src, total); err == nil {
+		return h.CompressMode[0], true, true
+	}
+
+	switch {
+	case bytes.HasPrefix(src, magicGZip):
+		return GZip, false, true
+	case bytes.HasPrefix(src, magicLz4):
+		return Lz4, false, true
+	case bytes.HasPrefix(src, magicZstd):
+		return Zstd, false, true
+	case isBz2(src):
+		return Bz2, false, true
+	case isZlib(src, total):
+		return Zlib, false, true
+	}
+	return Uncompressed, false, false
+}
+
+// isBz2 checks "BZh" + block size + block or end of stream magic
+func isBz2(src []byte) bool {
+	if len(src) < 10 || !bytes.HasPrefix(src, magicBz2) || src[3] < '1' || src[3] > '9' {
+		return false
+	}
+	return bytes.Equal(src[4:10], magicBlock) || bytes.Equal(src[4:10], magicEos)
+}
+
+// isZlib checks the RFC 1950 header: deflate,window <= 32K,no preset dictionary
+// and a valid FCHECK. Plain text passes that often("x^","HK"...),so the
+// bytes after it must inflate too: src must hold a whole stream or a prefix
+// of one cut by the end of src rather than by the end of the file.
+func isZlib(src []byte, total int64) bool {
+	if len(src) < 2 {
+		return false
+	}
+	cmf, flg := src[0], src[1]
+	if cmf&0x0F != 8 || cmf>>4 > 7 || flg&0x20 != 0 {
+		return false
+	}
+	if (uint16(cmf)<<8|uint16(flg))%31 != 0 {
+		return false
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+	_, err = io.CopyN(io.Discard, r, zlibSniffLimit)
+	switch err {
+	case nil, io.EOF:
+		return true
+	case io.ErrUnexpectedEOF:
+		return int64(len(src)) < total
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package cccompress
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sniffSample compresses data with the Default* codec of mode.
+func sniffSample(t *testing.T, mode byte, data []byte) []byte {
+	t.Helper()
+	spec := CodecSpec{Mode: mode}
+	codec, err := spec.Codec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := codec.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dst
+}
+
+func TestSniff(t *testing.T) {
+	data := bytes.Repeat([]byte("sniff me,"), 1000)
+	keyed, err := CompressWithOptions("xxx.yyy", data, &CCOptions{Mode: Zstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zlibData := sniffSample(t, Zlib, data)
+
+	tests := []struct {
+		name   string
+		src    []byte
+		mode   byte
+		header bool
+		ok     bool
+	}{
+		{"cc header", keyed, Zstd, true, true},
+		{"gzip", sniffSample(t, GZip, data), GZip, false, true},
+		{"zlib", zlibData, Zlib, false, true},
+		{"zstd", sniffSample(t, Zstd, data), Zstd, false, true},
+		{"bz2", sniffSample(t, Bz2, data), Bz2, false, true},
+		{"lz4", sniffSample(t, Lz4, data), Lz4, false, true},
+		{"plain", data, Uncompressed, false, false},
+		{"empty", nil, Uncompressed, false, false},
+		{"one byte", []byte{0x78}, Uncompressed, false, false},
+		// text with a valid zlib header that doesn't inflate
+		{"x^ text", []byte("x^ is a valid zlib header but not a stream"), Uncompressed, false, false},
+		{"HK text", []byte("HK is a valid zlib header too"), Uncompressed, false, false},
+		{"zlib cut", zlibData[:len(zlibData)/2], Uncompressed, false, false},
+		{"bz2 magic only", []byte("BZh9 plain text"), Uncompressed, false, false},
+		{"cc header cut", keyed[:20], Uncompressed, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, header, ok := Sniff(tt.src)
+			if ok != tt.ok || header != tt.header || (ok && mode != tt.mode) {
+				t.Errorf("Sniff[%v,%v,%v/%v,%v,%v]", mode, header, ok, tt.mode, tt.header, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	data := bytes.Repeat([]byte("sniff a file,"), 10000)
+	zlibData := sniffSample(t, Zlib, data)
+
+	tests := []struct {
+		name string
+		src  []byte
+		mode byte
+		ok   bool
+	}{
+		// the sniffed prefix is cut by the read,not by the end of the file
+		{"zlib", zlibData, Zlib, true},
+		{"gzip", sniffSample(t, GZip, data), GZip, true},
+		{"plain", data, Uncompressed, false},
+		{"x^ text", append([]byte("x^"), data...), Uncompressed, false},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(filePath, tt.src, 0644); err != nil {
+				t.Fatal(err)
+			}
+			mode, _, ok, err := SniffFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || (ok && mode != tt.mode) {
+				t.Errorf("SniffFile[%v,%v/%v,%v]", mode, ok, tt.mode, tt.ok)
+			}
+		})
+	}
+
+	if _, _, _, err := SniffFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("SniffFile[missing].err.nil")
+	}
+}
@@ -0,0 +1,329 @@
+package cccompress
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"CCServer.com/ccutility"
+)
+
+// content store layout,under the output folder
+const (
+	StoreIndexFile = "ccstore.json" // path -> hash index
+	StoreObjects   = "objects"      // objects/ab/cdef... one blob per hash
+)
+
+// CCStoreEntry is a path of the store index.
+type CCStoreEntry struct {
+	Hash     string      `json:"hash"` // sha256 of the original data,names the blob
+	Size     int64       `json:"size"` // original size
+	Mode     byte        `json:"mode"` // compress mode of the blob
+	FileMode os.FileMode `json:"file_mode"`
+	ModTime  time.Time   `json:"mod_time"`
+}
+
+// CCStoreIndex .
+type CCStoreIndex struct {
+	Params string                   `json:"params"` // codecs of the blobs,see codecParams
+	Files  map[string]*CCStoreEntry `json:"files"`
+}
+
+// blobPath .
+func blobPath(dir string, hash string) string {
+	return filepath.Join(dir, StoreObjects, hash[:2], hash[2:])
+}
+
+// storeTask .
+type storeTask struct {
+	*folderTask
+	entry *CCStoreEntry
+}
+
+// StoreFolders compresses every matching file under folders into the
+// content store opts.OutDir: each distinct content is written once as a blob
+// named by its hash,with the codec of the first path holding it,and
+// ccstore.json maps every path to its blob. Blobs already in the store are
+// kept unless the key or the codecs changed,those no path uses any more are
+// removed. Journal,Incremental and HotUpdate are refused.
+func StoreFolders(folders string, opts *CCFolderOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("StoreFolders[%v].opts.nil", folders)
+	}
+	if len(opts.OutDir) == 0 {
+		return 0, fmt.Errorf("StoreFolders[%v].ContentStore without OutDir", folders)
+	}
+	if folderAbs, e := filepath.Abs(folders); e == nil {
+		if outAbs, e := filepath.Abs(opts.OutDir); e == nil && outAbs == folderAbs {
+			return 0, fmt.Errorf("StoreFolders[%v].OutDir is the folder", folders)
+		}
+	}
+	if opts.Incremental || len(opts.Journal) > 0 || opts.HotUpdate != nil {
+		return 0, fmt.Errorf("StoreFolders[%v].ContentStore with Incremental,Journal or HotUpdate", folders)
+	}
+	if err = opts.validateRules(); err != nil {
+		return 0, fmt.Errorf("StoreFolders[%v].err[%v]", folders, err)
+	}
+	tasks, err := folderTasks("StoreFolders", folders, opts)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].rel < tasks[j].rel
+	})
+
+	// hash every file first,so a blob gets the codec of its first path
+	items := make([]*storeTask, len(tasks))
+	var lock = new(sync.Mutex)
+	runWorkers(len(tasks), opts.WorkerNum, func(idx int) {
+		t := tasks[idx]
+		fi, e := os.Stat(t.src)
+		var hash string
+		if e == nil {
+			hash, _, e = ccutility.HashFile(t.src)
+		}
+		if e != nil {
+			lock.Lock()
+			err = fmt.Errorf("StoreFolders[%v].err[%v]", t.src, e)
+			lock.Unlock()
+			return
+		}
+		items[idx] = &storeTask{folderTask: t, entry: &CCStoreEntry{
+			Hash:     hash,
+			Size:     fi.Size(),
+			Mode:     opts.specFor(t.rel).Mode,
+			FileMode: fi.Mode(),
+			ModTime:  fi.ModTime(),
+		}}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// a blob already stored keeps its mode,a blob the index doesn't know
+	// is written again,and every blob is when the codecs changed or the key
+	// no longer decodes them
+	index := &CCStoreIndex{
+		Params: codecParams("StoreFolders", opts),
+		Files:  make(map[string]*CCStoreEntry, len(items)),
+	}
+	stored := map[string]byte{}
+	if prev, e := OpenStore(opts.OutDir, opts.Key); e == nil && prev.index.Params == index.Params && prev.keyMatches() {
+		for _, entry := range prev.index.Files {
+			stored[entry.Hash] = entry.Mode
+		}
+	}
+
+	blobs := map[string]*storeTask{}
+	var unique []*storeTask
+	for _, it := range items {
+		if _, ok := blobs[it.entry.Hash]; !ok {
+			blobs[it.entry.Hash] = it
+			unique = append(unique, it)
+		}
+		index.Files[it.rel] = it.entry
+	}
+
+	var written int64
+	runWorkers(len(unique), opts.WorkerNum, func(idx int) {
+		it := unique[idx]
+		dstPath := blobPath(opts.OutDir, it.entry.Hash)
+		if mode, ok := stored[it.entry.Hash]; ok {
+			if _, e := os.Stat(dstPath); e == nil {
+				it.entry.Mode = mode
+				return
+			}
+		}
+		e := storeBlob(it.src, dstPath, it.entry, opts.Key, opts.specFor(it.rel))
+
+		lock.Lock()
+		defer lock.Unlock()
+		if e != nil {
+			log.Printf("StoreFolders[%v].err[%v]", it.src, e)
+			err = e
+			return
+		}
+		written++
+	})
+	if err != nil {
+		return 0, err
+	}
+	// every path reads the blob with its mode
+	for _, it := range items {
+		it.entry.Mode = blobs[it.entry.Hash].entry.Mode
+	}
+
+	src, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("StoreFolders[%v].Marshal.err[%v]", opts.OutDir, err)
+	}
+	if _, err = ccutility.WriteBinary(filepath.Join(opts.OutDir, StoreIndexFile), src); err != nil {
+		return 0, err
+	}
+	if err = pruneBlobs(opts.OutDir, blobs); err != nil {
+		return 0, err
+	}
+	log.Printf("StoreFolders[%v].files[%v].blobs[%v].written[%v]", opts.OutDir, len(items), len(unique), written)
+	return int64(len(items)), nil
+}
+
+// storeBlob .
+func storeBlob(srcPath string, dstPath string, entry *CCStoreEntry, key string, spec CodecSpec) error {
+	src, err := ccutility.ReadBinary(srcPath)
+	if err != nil {
+		return fmt.Errorf("storeBlob[%v].ReadBinary.err[%v]", srcPath, err)
+	}
+	// the file may have changed since it was hashed
+	if ccutility.HashBinary(src) != entry.Hash {
+		return fmt.Errorf("storeBlob[%v].changed while storing", srcPath)
+	}
+	codec, err := spec.Codec()
+	if err != nil {
+		return fmt.Errorf("storeBlob[%v].err[%v]", srcPath, err)
+	}
+	dst, err := CompressWithOptions(key, src, &CCOptions{Mode: spec.Mode, Codec: codec, Checksum: true})
+	if err != nil {
+		return fmt.Errorf("storeBlob[%v].Compress.err[%v]", srcPath, err)
+	}
+	_, err = ccutility.WriteBinary(dstPath, dst)
+	return err
+}
+
+// pruneBlobs removes the blobs of dir that aren't in keep.
+func pruneBlobs(dir string, keep map[string]*storeTask) error {
+	root := filepath.Join(dir, StoreObjects)
+	return filepath.Walk(root, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if filePath == root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		if _, ok := keep[strings.ReplaceAll(filepath.ToSlash(rel), "/", "")]; ok {
+			return nil
+		}
+		log.Printf("pruneBlobs[%v]", filePath)
+		return os.Remove(filePath)
+	})
+}
+
+// CCContentStore reads a store made by StoreFolders,resolving paths through
+// its index.
+type CCContentStore struct {
+	dir   string
+	key   string
+	index *CCStoreIndex
+}
+
+// OpenStore loads the index of the store at dir,key decodes the blobs.
+func OpenStore(dir string, key string) (*CCContentStore, error) {
+	src, err := os.ReadFile(filepath.Join(dir, StoreIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("OpenStore[%v].ReadFile.err[%v]", dir, err)
+	}
+	index := &CCStoreIndex{}
+	if err = json.Unmarshal(src, index); err != nil {
+		return nil, fmt.Errorf("OpenStore[%v].Unmarshal.err[%v]", dir, err)
+	}
+	for name, e := range index.Files {
+		if _, err = cleanEntryName(name); err != nil || len(e.Hash) != 64 || strings.Trim(e.Hash, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("OpenStore[%v].entry[%v].invalid", dir, name)
+		}
+	}
+	return &CCContentStore{dir: dir, key: key, index: index}, nil
+}
+
+// IsStore reports whether dir holds a content store.
+func IsStore(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, StoreIndexFile))
+	return err == nil && !fi.IsDir()
+}
+
+// Paths returns every path of the index,sorted.
+func (p *CCContentStore) Paths() []string {
+	ret := make([]string, 0, len(p.index.Files))
+	for name := range p.index.Files {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Entry returns the entry of a path,nil when there is none.
+func (p *CCContentStore) Entry(name string) *CCStoreEntry {
+	return p.index.Files[name]
+}
+
+// BlobPath is the file holding the blob of hash.
+func (p *CCContentStore) BlobPath(hash string) string {
+	return blobPath(p.dir, hash)
+}
+
+// keyMatches reports whether the key of p decodes the blobs,by reading the
+// first path. A blob is checked against its hash,so a wrong key can't pass.
+func (p *CCContentStore) keyMatches() bool {
+	paths := p.Paths()
+	if len(paths) == 0 {
+		return true
+	}
+	_, err := p.ReadFile(paths[0])
+	return err == nil
+}
+
+// ReadFile decodes the blob of a path and checks it against its hash.
+func (p *CCContentStore) ReadFile(name string) ([]byte, error) {
+	e := p.index.Files[name]
+	if e == nil {
+		return nil, fmt.Errorf("CCContentStore.ReadFile[%v].err[%v]", name, os.ErrNotExist)
+	}
+	src, err := ccutility.ReadBinary(p.BlobPath(e.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("CCContentStore.ReadFile[%v].ReadBinary.err[%v]", name, err)
+	}
+	_, dst, err := Decompress(p.key, src, e.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("CCContentStore.ReadFile[%v].err[%v]", name, err)
+	}
+	if ccutility.HashBinary(dst) != e.Hash {
+		return nil, fmt.Errorf("CCContentStore.ReadFile[%v].hash.no match", name)
+	}
+	return dst, nil
+}
+
+// UnpackStore writes every path of the store at dir under outDir,keeping
+// the file modes and mtimes.
+func UnpackStore(dir string, outDir string, key string) (successed int64, err error) {
+	s, err := OpenStore(dir, key)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range s.Paths() {
+		dst, e := s.ReadFile(name)
+		if e != nil {
+			return successed, fmt.Errorf("UnpackStore[%v].err[%v]", dir, e)
+		}
+		filePath := filepath.Join(outDir, filepath.FromSlash(name))
+		if _, e = ccutility.WriteBinary(filePath, dst); e != nil {
+			return successed, fmt.Errorf("UnpackStore[%v].err[%v]", filePath, e)
+		}
+		entry := s.Entry(name)
+		if e = ccutility.ApplyFileInfo(filePath, entry.FileMode.Perm(), entry.ModTime); e != nil {
+			return successed, fmt.Errorf("UnpackStore[%v].err[%v]", filePath, e)
+		}
+		successed++
+	}
+	return successed, nil
+}
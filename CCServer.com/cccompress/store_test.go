@@ -0,0 +1,203 @@
+package cccompress
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"CCServer.com/ccutility"
+)
+
+// storeSample writes files,name -> data,under dir.
+func storeSample(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// storeBlobs returns the modification time of every blob of the store at dir.
+func storeBlobs(t *testing.T, dir string) map[string]time.Time {
+	t.Helper()
+	ret := map[string]time.Time{}
+	root := filepath.Join(dir, StoreObjects)
+	err := filepath.Walk(root, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		ret[filePath] = fi.ModTime()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestStoreFolders(t *testing.T) {
+	files := map[string]string{
+		"a.txt":     "shared content",
+		"sub/b.txt": "shared content",
+		"sub/c.txt": "other content",
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		mode    int
+		change  map[string]string // written to the source before the second run
+		remove  []string          // removed from the source before the second run
+		blobs   int               // blobs after the second run
+		written bool              // whether the kept blobs are written again
+	}{
+		{"unchanged", "xxx.yyy", GZip, nil, nil, 2, false},
+		{"new file", "xxx.yyy", GZip, map[string]string{"d.txt": "new content"}, nil, 3, false},
+		{"removed file", "xxx.yyy", GZip, nil, []string{"sub/c.txt"}, 1, false},
+		{"other key", "zzz.www", GZip, nil, nil, 2, true},
+		{"other codec", "xxx.yyy", Zstd, nil, nil, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+			storeSample(t, src, files)
+
+			opts := NewFolderOptions("", "xxx.yyy", GZip, false, 2)
+			opts.OutDir = out
+			if n, err := StoreFolders(src, opts); err != nil || n != int64(len(files)) {
+				t.Fatalf("StoreFolders[%v].err[%v]", n, err)
+			}
+			before := storeBlobs(t, out)
+			if len(before) != 2 {
+				t.Fatalf("StoreFolders.blobs[%v].no dedup", len(before))
+			}
+			// blob mtimes must differ if they are written again
+			old := time.Now().Add(-time.Hour)
+			for filePath := range before {
+				if err := os.Chtimes(filePath, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want := map[string]string{}
+			for name, data := range files {
+				want[name] = data
+			}
+			storeSample(t, src, tt.change)
+			for name, data := range tt.change {
+				want[name] = data
+			}
+			for _, name := range tt.remove {
+				if err := os.Remove(filepath.Join(src, filepath.FromSlash(name))); err != nil {
+					t.Fatal(err)
+				}
+				delete(want, name)
+			}
+
+			opts = NewFolderOptions("", tt.key, tt.mode, false, 2)
+			opts.OutDir = out
+			if _, err := StoreFolders(src, opts); err != nil {
+				t.Fatalf("StoreFolders.err[%v]", err)
+			}
+			after := storeBlobs(t, out)
+			if len(after) != tt.blobs {
+				t.Errorf("StoreFolders.blobs[%v/%v]", len(after), tt.blobs)
+			}
+			for filePath, modTime := range after {
+				if _, ok := before[filePath]; ok && modTime.After(old) != tt.written {
+					t.Errorf("StoreFolders[%v].written[%v/%v]", filePath, modTime.After(old), tt.written)
+				}
+			}
+
+			s, err := OpenStore(out, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.Paths()) != len(want) {
+				t.Fatalf("OpenStore.paths[%v/%v]", len(s.Paths()), len(want))
+			}
+			for name, data := range want {
+				if got, err := s.ReadFile(name); err != nil || string(got) != data {
+					t.Errorf("ReadFile[%v].got[%q].err[%v]", name, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreReadFileWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	storeSample(t, src, map[string]string{"a.txt": "hello store"})
+	opts := NewFolderOptions("", "xxx.yyy", GZip, false, 1)
+	opts.OutDir = out
+	if _, err := StoreFolders(src, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index must not give the key away
+	index, err := ccutility.ReadBinary(filepath.Join(out, StoreIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), ccutility.HashBinary([]byte("xxx.yyy"))) {
+		t.Errorf("StoreIndexFile.holds the key hash")
+	}
+
+	for _, key := range []string{"", "zzz.www"} {
+		s, err := OpenStore(out, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.ReadFile("a.txt"); err == nil {
+			t.Errorf("key[%v].ReadFile.err.nil", key)
+		}
+	}
+}
+
+func TestUnpackStoreFileMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{"plain", 0640, 0640},
+		{"setuid", os.ModeSetuid | os.ModeSetgid | 0755, 0755},
+		{"sticky", os.ModeSticky | 0700, 0700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+			storeSample(t, src, map[string]string{"a.sh": "#!/bin/sh"})
+			if err := os.Chmod(filepath.Join(src, "a.sh"), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			opts := NewFolderOptions("", "xxx.yyy", GZip, false, 1)
+			opts.OutDir = out
+			if _, err := StoreFolders(src, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if _, err := UnpackStore(out, dst, "xxx.yyy"); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(filepath.Join(dst, "a.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode() != tt.want {
+				t.Errorf("UnpackStore.mode[%v/%v]", fi.Mode(), tt.want)
+			}
+		})
+	}
+}
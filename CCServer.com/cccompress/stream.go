@@ -0,0 +1,289 @@
+package cccompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"CCServer.com/ccutility"
+)
+
+// StreamLen is written as CompressedLen and OriginLen by CCWriter,which
+// can't know them when the header goes out. Readers take the rest of the
+// input as the body.
+const StreamLen int64 = -1
+
+// streamCodec .
+func streamCodec(codec Codec, mode byte) (StreamCodec, error) {
+	sc, ok := codec.(StreamCodec)
+	if !ok {
+		return nil, fmt.Errorf("%v.not a StreamCodec", codecName(mode))
+	}
+	return sc, nil
+}
+
+// CCWriter compresses what is written to it into a CC stream,readable by
+// CCReader and by Decompress once complete.
+type CCWriter struct {
+	w     io.Writer
+	a     []string
+	ok    bool
+	head  []byte // the first obfuscateLen bytes of the body,held until full
+	codec io.WriteCloser
+	total int64 // bytes written to w
+}
+
+// NewWriter writes the header at once when key asks for obfuscation,with
+// ext kept in a v2 header,then the body as it is compressed.
+func NewWriter(w io.Writer, key string, spec CodecSpec, ext []TagCCHeaderExt) (*CCWriter, error) {
+	codec, err := spec.Codec()
+	if err != nil {
+		return nil, fmt.Errorf("NewWriter[%v].err[%v]", key, err)
+	}
+	sc, err := streamCodec(codec, spec.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("NewWriter[%v].err[%v]", key, err)
+	}
+
+	p := &CCWriter{w: w}
+	p.a, p.ok = splitKey(key)
+	if p.ok {
+		header := newHeader(spec.Mode, 0, 0, ext)
+		copy(header.CompressedLen[:], ccutility.Int64ToBytes(StreamLen))
+		copy(header.OriginLen[:], ccutility.Int64ToBytes(StreamLen))
+
+		buf := new(bytes.Buffer)
+		if err = header.write(buf); err != nil {
+			return nil, fmt.Errorf("NewWriter[%v].binary.Write.err[%v]", key, err)
+		}
+		if err = p.flush(buf.Bytes()); err != nil {
+			return nil, fmt.Errorf("NewWriter[%v].Write.err[%v]", key, err)
+		}
+		p.head = make([]byte, 0, obfuscateLen)
+	}
+
+	if p.codec, err = sc.NewWriter(bodyWriter{p}); err != nil {
+		return nil, fmt.Errorf("NewWriter[%v].%v.NewWriter.err[%v]", key, codecName(spec.Mode), err)
+	}
+	return p, nil
+}
+
+// Write .
+func (p *CCWriter) Write(b []byte) (int, error) {
+	return p.codec.Write(b)
+}
+
+// Close flushes the codec,it doesn't close the underlying writer.
+func (p *CCWriter) Close() error {
+	if err := p.codec.Close(); err != nil {
+		return err
+	}
+	// a body shorter than obfuscateLen is obfuscated as a whole,like Compress
+	if p.head != nil {
+		obfuscate(p.head, p.a)
+		err := p.flush(p.head)
+		p.head = nil
+		return err
+	}
+	return nil
+}
+
+// Written is the number of bytes written to the underlying writer so far.
+func (p *CCWriter) Written() int64 {
+	return p.total
+}
+
+// flush .
+func (p *CCWriter) flush(b []byte) error {
+	n, err := p.w.Write(b)
+	p.total += int64(n)
+	return err
+}
+
+// bodyWriter receives the compressed body and obfuscates its head.
+type bodyWriter struct {
+	p *CCWriter
+}
+
+// Write .
+func (b bodyWriter) Write(data []byte) (int, error) {
+	p := b.p
+	n := len(data)
+	if p.head != nil {
+		m := obfuscateLen - len(p.head)
+		if m > len(data) {
+			m = len(data)
+		}
+		p.head = append(p.head, data[:m]...)
+		data = data[m:]
+		if len(p.head) < obfuscateLen {
+			return n, nil
+		}
+		obfuscate(p.head, p.a)
+		head := p.head
+		p.head = nil
+		if err := p.flush(head); err != nil {
+			return 0, err
+		}
+	}
+	if len(data) > 0 {
+		if err := p.flush(data); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// CCReader decompresses a CC stream.
+type CCReader struct {
+	Header *CCHeader // nil without obfuscation key
+
+	codec  io.ReadCloser
+	origin int64 // expected size,StreamLen when unknown
+	total  int64
+	crc    hash.Hash32 // nil without CRC32 extension
+}
+
+// NewReader reads the header when key asks for obfuscation,the mode is
+// then the one of the header; otherwise compressMode is used.
+func NewReader(r io.Reader, key string, compressMode byte) (*CCReader, error) {
+	p := &CCReader{origin: StreamLen}
+	body := r
+
+	if a, ok := splitKey(key); ok {
+		header, err := readHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("NewReader[%v].err[%v]", key, err)
+		}
+		p.Header = header
+		compressMode = header.CompressMode[0]
+		p.origin = ccutility.BytesToInt64(header.OriginLen[:])
+		if _, ok := header.CRC32(); ok {
+			p.crc = crc32.NewIEEE()
+		}
+
+		if l := ccutility.BytesToInt64(header.CompressedLen[:]); l != StreamLen {
+			r = io.LimitReader(r, l)
+		}
+		head := make([]byte, obfuscateLen)
+		n, err := io.ReadFull(r, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, fmt.Errorf("NewReader[%v].Read.err[%v]", key, err)
+		}
+		head = head[:n]
+		obfuscate(head, a)
+		body = io.MultiReader(bytes.NewReader(head), r)
+	}
+
+	info := CodecByMode(compressMode)
+	if info == nil {
+		return nil, fmt.Errorf("NewReader[%v].mode[%v].unknown", key, compressMode)
+	}
+	sc, err := streamCodec(info.Default(), compressMode)
+	if err != nil {
+		return nil, fmt.Errorf("NewReader[%v].err[%v]", key, err)
+	}
+	if p.codec, err = sc.NewReader(body); err != nil {
+		return nil, fmt.Errorf("NewReader[%v].%v.NewReader.err[%v]", key, info.Name, err)
+	}
+	return p, nil
+}
+
+// Read .
+func (p *CCReader) Read(b []byte) (int, error) {
+	n, err := p.codec.Read(b)
+	p.total += int64(n)
+	if p.crc != nil {
+		p.crc.Write(b[:n])
+	}
+	if err != io.EOF {
+		return n, err
+	}
+	if p.origin != StreamLen && p.total != p.origin {
+		return n, fmt.Errorf("CCReader.size[%v/%v].no match", p.total, p.origin)
+	}
+	if p.crc != nil {
+		if sum, _ := p.Header.CRC32(); p.crc.Sum32() != sum {
+			return n, fmt.Errorf("CCReader.crc32[%08x/%08x].no match", sum, p.crc.Sum32())
+		}
+	}
+	return n, err
+}
+
+// Close closes the codec,not the underlying reader.
+func (p *CCReader) Close() error {
+	return p.codec.Close()
+}
+
+// readHeader reads a header and its extensions from the start of r.
+func readHeader(r io.Reader) (*CCHeader, error) {
+	header := &CCHeader{}
+	fixed := binary.Size(header.TagCCHeaderInfo)
+	buf := make([]byte, fixed, fixed+extLenSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("readHeader.Read.err[%v]", err)
+	}
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &header.TagCCHeaderInfo); err != nil {
+		return nil, fmt.Errorf("readHeader.binary.Read.err[%v]", err)
+	}
+	if !header.IsValid() {
+		return nil, fmt.Errorf("readHeader.header.IsValid.false")
+	}
+	header.size = fixed
+	if !header.IsV2() {
+		return header, nil
+	}
+
+	var extLen uint32
+	if err := binary.Read(r, binary.BigEndian, &extLen); err != nil {
+		return nil, fmt.Errorf("readHeader.ext.size.err[%v]", err)
+	}
+	if extLen > MaxExtLen {
+		return nil, fmt.Errorf("readHeader.ext.size[%v].too large", extLen)
+	}
+	ext := make([]byte, extLen)
+	if _, err := io.ReadFull(r, ext); err != nil {
+		return nil, fmt.Errorf("readHeader.ext.Read.err[%v]", err)
+	}
+	if err := header.readExt(ext); err != nil {
+		return nil, err
+	}
+	header.size += extLenSize + int(extLen)
+	return header, nil
+}
+
+// CompressStream compresses src into dst,see NewWriter. It returns the
+// number of bytes written to dst.
+func CompressStream(dst io.Writer, src io.Reader, key string, spec CodecSpec) (dlen int64, err error) {
+	w, err := NewWriter(dst, key, spec, nil)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = io.Copy(w, src); err != nil {
+		w.Close()
+		return w.Written(), fmt.Errorf("CompressStream[%v].Copy.err[%v]", key, err)
+	}
+	if err = w.Close(); err != nil {
+		return w.Written(), fmt.Errorf("CompressStream[%v].Close.err[%v]", key, err)
+	}
+	return w.Written(), nil
+}
+
+// DecompressStream decompresses src into dst,see NewReader. It returns the
+// number of bytes written to dst.
+func DecompressStream(dst io.Writer, src io.Reader, key string, compressMode byte) (dlen int64, err error) {
+	r, err := NewReader(src, key, compressMode)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	dlen, err = io.Copy(dst, r)
+	if err != nil {
+		return dlen, fmt.Errorf("DecompressStream[%v].Copy.err[%v]", key, err)
+	}
+	return dlen, nil
+}
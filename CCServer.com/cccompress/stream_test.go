@@ -0,0 +1,108 @@
+package cccompress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	data := deltaSample(7, 256<<10)
+
+	tests := []struct {
+		key  string
+		mode byte
+		size int
+	}{
+		{"", GZip, 1000},
+		{"", Zstd, 1000},
+		{"xxx.yyy", GZip, 0},
+		{"xxx.yyy", GZip, 10},
+		{"xxx.yyy", GZip, obfuscateLen - 1},
+		{"xxx.yyy", GZip, obfuscateLen},
+		{"xxx.yyy", Zlib, 64 << 10},
+		{"xxx.yyy", Zstd, len(data)},
+		{"xxx.yyy", Brotli, 64 << 10},
+		{"xxx.yyy", Bz2, 64 << 10},
+		{"xxx.yyy", Lz4, 64 << 10},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v.%v.%v", tt.key, codecName(tt.mode), tt.size), func(t *testing.T) {
+			src := data[:tt.size]
+			spec := CodecSpec{Mode: tt.mode}
+
+			// streamed in,read back as a stream and as a whole
+			buf := new(bytes.Buffer)
+			n, err := CompressStream(buf, bytes.NewReader(src), tt.key, spec)
+			if err != nil || n != int64(buf.Len()) {
+				t.Fatalf("CompressStream[%v/%v].err[%v]", n, buf.Len(), err)
+			}
+			out := new(bytes.Buffer)
+			if _, err = DecompressStream(out, bytes.NewReader(buf.Bytes()), tt.key, tt.mode); err != nil {
+				t.Fatalf("DecompressStream.err[%v]", err)
+			}
+			if !bytes.Equal(out.Bytes(), src) {
+				t.Errorf("DecompressStream.no match")
+			}
+			if _, got, err := Decompress(tt.key, buf.Bytes(), tt.mode); err != nil || !bytes.Equal(got, src) {
+				t.Errorf("Decompress.stream.err[%v]", err)
+			}
+
+			// compressed as a whole,read back as a stream
+			whole, err := CompressWithOptions(tt.key, src, &CCOptions{Mode: tt.mode, Checksum: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Reset()
+			if _, err = DecompressStream(out, bytes.NewReader(whole), tt.key, tt.mode); err != nil {
+				t.Fatalf("DecompressStream.whole.err[%v]", err)
+			}
+			if !bytes.Equal(out.Bytes(), src) {
+				t.Errorf("DecompressStream.whole.no match")
+			}
+		})
+	}
+}
+
+func TestStreamHeaderCorrupted(t *testing.T) {
+	src := deltaSample(8, 4096)
+	whole, err := CompressWithOptions("xxx.yyy", src, &CCOptions{Mode: GZip, Checksum: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := binary.Size(TagCCHeaderInfo{})
+
+	hugeExt := append([]byte(nil), whole...)
+	binary.BigEndian.PutUint32(hugeExt[fixed:], MaxExtLen+1)
+
+	longExt := append([]byte(nil), whole...)
+	binary.BigEndian.PutUint32(longExt[fixed:], MaxExtLen)
+
+	badCRC := append([]byte(nil), whole...)
+	badCRC[len(badCRC)-1] ^= 0xFF
+
+	tests := []struct {
+		name string
+		src  []byte
+		err  string // part of the expected error
+	}{
+		{"empty", nil, "readHeader.Read"},
+		{"header cut", whole[:fixed-1], "readHeader.Read"},
+		{"ext length cut", whole[:fixed+2], "readHeader.ext.size"},
+		{"ext length over MaxExtLen", hugeExt, "too large"},
+		{"ext past the end", longExt, "readHeader.ext.Read"},
+		{"bad magic", append([]byte("XXXX"), whole[4:]...), "IsValid"},
+		{"body damaged", badCRC, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			_, err := DecompressStream(out, bytes.NewReader(tt.src), "xxx.yyy", GZip)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("DecompressStream.err[%v/%v]", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package cccompress
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"CCServer.com/ccutility"
+)
+
+// Verify decodes src in memory,src is left untouched. With a header the
+// length fields are checked against src and the result,and the CRC32
+// extension when there is one.
+func Verify(key string, src []byte, compressMode byte) (header *CCHeader, dst []byte, err error) {
+	// Decompress works in place
+	buf := make([]byte, len(src))
+	copy(buf, src)
+	return Decompress(key, buf, compressMode)
+}
+
+// VerifyFile checks that filePath decodes,and that it decodes to sourcePath
+// unless sourcePath is empty.
+func VerifyFile(filePath string, key string, compressMode int, sourcePath string) error {
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return fmt.Errorf("VerifyFile[%v].ReadBinary.err[%v]", filePath, err)
+	}
+	_, dst, err := Verify(key, src, byte(compressMode))
+	if err != nil {
+		return fmt.Errorf("VerifyFile[%v].err[%v]", filePath, err)
+	}
+	if len(sourcePath) == 0 {
+		return nil
+	}
+
+	origin, err := ccutility.ReadBinary(sourcePath)
+	if err != nil {
+		return fmt.Errorf("VerifyFile[%v].source[%v].err[%v]", filePath, sourcePath, err)
+	}
+	if !bytes.Equal(dst, origin) {
+		return fmt.Errorf("VerifyFile[%v].source[%v].no match", filePath, sourcePath)
+	}
+	return nil
+}
+
+// VerifyFolders verifies every matching file under folders. With sourceDir
+// each file is also compared to the file at the same relative path in it.
+// Failures are logged,err is the last one.
+func VerifyFolders(folders string, opts *CCFolderOptions, sourceDir string) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("VerifyFolders[%v].opts.nil", folders)
+	}
+
+	filter := opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(opts.Ext)
+	}
+
+	var allFile []string
+	allFile, err = ccutility.GetAllFileByFilter(folders, filter, allFile)
+	if err != nil {
+		return 0, err
+	}
+
+	var failed int64
+	var lock = new(sync.RWMutex)
+	runWorkers(len(allFile), opts.WorkerNum, func(idx int) {
+		filePath := allFile[idx]
+
+		var sourcePath string
+		rel, e := filepath.Rel(folders, filePath)
+		if e == nil {
+			if len(sourceDir) > 0 {
+				sourcePath = filepath.Join(sourceDir, rel)
+			}
+			e = VerifyFile(filePath, opts.Key, int(opts.specFor(filepath.ToSlash(rel)).Mode), sourcePath)
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if e != nil {
+			log.Printf("VerifyFolders[%v].err[%v]", filePath, e)
+			failed++
+			err = e
+			return
+		}
+		successed++
+	})
+
+	if failed > 0 {
+		log.Printf("VerifyFolders[%v].failed[%v/%v]", folders, failed, len(allFile))
+	}
+	return successed, err
+}
@@ -0,0 +1,270 @@
+package cccompress
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"CCServer.com/ccutility"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch defaults
+const (
+	DefaultWatchInterval = 2 * time.Second
+	DefaultWatchRescan   = time.Minute // the safety net rescan with notifications
+	DefaultWatchDebounce = 500 * time.Millisecond
+)
+
+// CCWatchOptions .
+type CCWatchOptions struct {
+	// Interval is the time between two scans of the folder,0 means
+	// DefaultWatchInterval when polling and DefaultWatchRescan with
+	// notifications,where scans only catch what they missed.
+	Interval time.Duration
+
+	// Debounce is how long a file must keep its size and mtime before it is
+	// processed,so files still being written are left alone. 0 means
+	// DefaultWatchDebounce.
+	Debounce time.Duration
+
+	// Poll only polls,otherwise file system notifications(inotify,kqueue,
+	// ReadDirectoryChangesW...) are used where available.
+	Poll bool
+
+	// Process makes dstPath from srcPath,spec is the codec the folder options
+	// give the file. nil means compressing it with the key of the folder
+	// options. It should give dstPath the mtime of srcPath,which is how an up
+	// to date output is told at start.
+	Process func(srcPath string, dstPath string, spec CodecSpec) error
+}
+
+// watchState is what the last scan saw of a file.
+type watchState struct {
+	size    int64
+	modTime time.Time
+	changed time.Time // when size or mtime last changed
+	pending bool
+}
+
+// ccWatcher .
+type ccWatcher struct {
+	folders string
+	opts    *CCFolderOptions
+	wopts   CCWatchOptions
+	outAbs  string
+	files   map[string]*watchState
+}
+
+// WatchFolders compresses every matching file under folders into
+// opts.OutDir,then every file that is new or modified,once it has been
+// stable for the debounce time,until ctx is done. Outputs of removed files
+// are left. Journal,Incremental,ContentStore and HotUpdate don't apply.
+func WatchFolders(ctx context.Context, folders string, opts *CCFolderOptions, wopts *CCWatchOptions) error {
+	if opts == nil {
+		return fmt.Errorf("WatchFolders[%v].opts.nil", folders)
+	}
+	if len(opts.OutDir) == 0 {
+		return fmt.Errorf("WatchFolders[%v].OutDir.empty", folders)
+	}
+	if err := opts.validateRules(); err != nil {
+		return fmt.Errorf("WatchFolders[%v].err[%v]", folders, err)
+	}
+
+	// every file would be under the output folder and left alone
+	if folderAbs, e := filepath.Abs(folders); e == nil {
+		if outAbs, e := filepath.Abs(opts.OutDir); e == nil && outAbs == folderAbs {
+			return fmt.Errorf("WatchFolders[%v].OutDir is the folder", folders)
+		}
+	}
+
+	w := &ccWatcher{folders: folders, opts: opts, files: map[string]*watchState{}}
+	if wopts != nil {
+		w.wopts = *wopts
+	}
+	if w.wopts.Debounce <= 0 {
+		w.wopts.Debounce = DefaultWatchDebounce
+	}
+	if w.wopts.Process == nil {
+		w.wopts.Process = func(srcPath string, dstPath string, spec CodecSpec) error {
+			_, err := CompressFileSpecTo(srcPath, dstPath, opts.Key, spec)
+			return err
+		}
+	}
+	w.outAbs, _ = filepath.Abs(opts.OutDir)
+
+	var notify *fsnotify.Watcher
+	if !w.wopts.Poll {
+		var err error
+		if notify, err = fsnotify.NewWatcher(); err != nil {
+			log.Printf("WatchFolders[%v].notifications unavailable,polling.err[%v]", folders, err)
+		} else {
+			defer notify.Close()
+			if err = w.watchTree(notify, folders); err != nil {
+				return err
+			}
+		}
+	}
+	if w.wopts.Interval <= 0 {
+		w.wopts.Interval = DefaultWatchInterval
+		if notify != nil {
+			w.wopts.Interval = DefaultWatchRescan
+		}
+	}
+
+	var events chan fsnotify.Event
+	var errors chan error
+	if notify != nil {
+		events, errors = notify.Events, notify.Errors
+	}
+	log.Printf("WatchFolders[%v].out[%v].notify[%v].interval[%v].debounce[%v]", folders, opts.OutDir, notify != nil, w.wopts.Interval, w.wopts.Debounce)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-events:
+			if w.inOutDir(ev.Name) {
+				continue
+			}
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err = w.watchTree(notify, ev.Name); err != nil {
+						log.Printf("WatchFolders[%v].err[%v]", ev.Name, err)
+					}
+				}
+			}
+			// scan once the burst of events is over
+			timer.Reset(w.wopts.Debounce)
+		case err := <-errors:
+			log.Printf("WatchFolders[%v].notify.err[%v]", folders, err)
+		case <-timer.C:
+			timer.Reset(w.scan(time.Now()))
+		}
+	}
+}
+
+// inOutDir reports whether filePath is under the output folder,which may be
+// inside the watched one.
+func (p *ccWatcher) inOutDir(filePath string) bool {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	return inDir(abs, p.outAbs)
+}
+
+// watchTree adds dir and its sub folders to notify.
+func (p *ccWatcher) watchTree(notify *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if p.inOutDir(filePath) {
+			return filepath.SkipDir
+		}
+		if err = notify.Add(filePath); err != nil {
+			return fmt.Errorf("watchTree[%v].err[%v]", filePath, err)
+		}
+		return nil
+	})
+}
+
+// scan processes the files stable for the debounce time and returns when
+// to scan again.
+func (p *ccWatcher) scan(now time.Time) time.Duration {
+	filter := p.opts.Filter
+	if filter == nil {
+		filter = ccutility.NewFileFilter(p.opts.Ext)
+	}
+	var allFile []string
+	allFile, err := ccutility.GetAllFileByFilter(p.folders, filter, allFile)
+	if err != nil {
+		log.Printf("WatchFolders[%v].err[%v]", p.folders, err)
+		return p.wopts.Interval
+	}
+
+	next := p.wopts.Interval
+	seen := make(map[string]bool, len(allFile))
+	var ready []*folderTask
+	for _, f := range allFile {
+		if p.inOutDir(f) {
+			continue
+		}
+		rel, err := filepath.Rel(p.folders, f)
+		if err != nil {
+			continue
+		}
+		t := &folderTask{src: f, dst: filepath.Join(p.opts.OutDir, rel), rel: filepath.ToSlash(rel)}
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		seen[t.rel] = true
+
+		s := p.files[t.rel]
+		if s == nil {
+			s = &watchState{size: fi.Size(), modTime: fi.ModTime(), changed: now, pending: !upToDate(t.dst, fi)}
+			p.files[t.rel] = s
+		} else if s.size != fi.Size() || !s.modTime.Equal(fi.ModTime()) {
+			s.size, s.modTime, s.changed, s.pending = fi.Size(), fi.ModTime(), now, true
+		}
+		if !s.pending {
+			continue
+		}
+		if wait := p.wopts.Debounce - now.Sub(s.changed); wait > 0 {
+			if wait < next {
+				next = wait
+			}
+			continue
+		}
+		s.pending = false
+		ready = append(ready, t)
+	}
+	for rel := range p.files {
+		if !seen[rel] {
+			delete(p.files, rel)
+		}
+	}
+
+	if len(ready) > 0 {
+		p.process(ready)
+	}
+	return next
+}
+
+// upToDate reports whether dstPath was made from a source like fi.
+func upToDate(dstPath string, fi os.FileInfo) bool {
+	dfi, err := os.Stat(dstPath)
+	return err == nil && dfi.ModTime().Equal(fi.ModTime())
+}
+
+// process runs Process over tasks with opts.WorkerNum workers,a failed file
+// is tried again when it changes.
+func (p *ccWatcher) process(tasks []*folderTask) {
+	var successed int64
+	var lock = new(sync.Mutex)
+	runWorkers(len(tasks), p.opts.WorkerNum, func(idx int) {
+		t := tasks[idx]
+		err := p.wopts.Process(t.src, t.dst, p.opts.specFor(t.rel))
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			log.Printf("WatchFolders[%v].err[%v]", t.src, err)
+			return
+		}
+		log.Printf("WatchFolders[%v].done[%v]", t.src, t.dst)
+		successed++
+	})
+	log.Printf("WatchFolders[%v].processed[%v/%v]", p.folders, successed, len(tasks))
+}
@@ -0,0 +1,153 @@
+package cccompress
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watchStep is one scan of TestWatchScan.
+type watchStep struct {
+	at   time.Duration                  // since the first scan
+	edit func(t *testing.T, src string) // run before the scan
+	want []string                       // files processed by the scan
+}
+
+func TestWatchScan(t *testing.T) {
+	const debounce = time.Second
+	write := func(name string, data string) func(t *testing.T, src string) {
+		return func(t *testing.T, src string) {
+			writeTestFile(t, filepath.Join(src, filepath.FromSlash(name)), data)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, src string, out string)
+		fail  map[string]int // times Process fails for a file
+		steps []watchStep
+	}{
+		{"new file waits for the debounce", nil, nil, []watchStep{
+			{0, nil, nil},
+			{debounce / 2, nil, nil},
+			{debounce, nil, []string{"a.txt"}},
+			{2 * debounce, nil, nil},
+		}},
+		{"a change restarts the debounce", nil, nil, []watchStep{
+			{0, nil, nil},
+			{debounce * 9 / 10, write("a.txt", "still being written"), nil},
+			{debounce * 15 / 10, nil, nil},
+			{debounce * 19 / 10, nil, []string{"a.txt"}},
+		}},
+		{"modified after processing", nil, nil, []watchStep{
+			{0, nil, nil},
+			{debounce, nil, []string{"a.txt"}},
+			{2 * debounce, write("a.txt", "edited once done"), nil},
+			{3 * debounce, nil, []string{"a.txt"}},
+		}},
+		{"new file after start", nil, nil, []watchStep{
+			{0, nil, nil},
+			{debounce, write("sub/b.txt", "added"), []string{"a.txt"}},
+			{2 * debounce, nil, []string{"sub/b.txt"}},
+		}},
+		{"up to date output", func(t *testing.T, src string, out string) {
+			writeTestFile(t, filepath.Join(out, "a.txt"), "made before")
+			fi, err := os.Stat(filepath.Join(src, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Chtimes(filepath.Join(out, "a.txt"), fi.ModTime(), fi.ModTime())
+		}, nil, []watchStep{
+			{0, nil, nil},
+			{2 * debounce, nil, nil},
+		}},
+		{"failed file waits for a change", nil, map[string]int{"a.txt": 1}, []watchStep{
+			{0, nil, nil},
+			{debounce, nil, []string{"a.txt"}},
+			{3 * debounce, nil, nil},
+			{4 * debounce, write("a.txt", "fixed"), nil},
+			{5 * debounce, nil, []string{"a.txt"}},
+		}},
+		{"output folder inside", func(t *testing.T, src string, out string) {
+			writeTestFile(t, filepath.Join(src, "out", "old.txt"), "an output")
+		}, nil, []watchStep{
+			{0, nil, nil},
+			{debounce, nil, []string{"a.txt"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "src")
+			out := filepath.Join(src, "out")
+			writeTestFile(t, filepath.Join(src, "a.txt"), "hello watch")
+			if tt.setup != nil {
+				tt.setup(t, src, out)
+			}
+
+			var processed []string
+			fail := map[string]int{}
+			for k, v := range tt.fail {
+				fail[k] = v
+			}
+			opts := NewFolderOptions("", "", GZip, false, 1)
+			opts.OutDir = out
+			w := &ccWatcher{folders: src, opts: opts, files: map[string]*watchState{}}
+			w.outAbs, _ = filepath.Abs(out)
+			w.wopts = CCWatchOptions{Interval: time.Hour, Debounce: debounce, Process: func(srcPath string, dstPath string, spec CodecSpec) error {
+				rel, _ := filepath.Rel(src, srcPath)
+				rel = filepath.ToSlash(rel)
+				processed = append(processed, rel)
+				if fail[rel] > 0 {
+					fail[rel]--
+					return fmt.Errorf("failed on purpose")
+				}
+				return nil
+			}}
+
+			start := time.Now()
+			for i, step := range tt.steps {
+				if step.edit != nil {
+					step.edit(t, src)
+				}
+				processed = nil
+				next := w.scan(start.Add(step.at))
+				sort.Strings(processed)
+				if strings.Join(processed, ",") != strings.Join(step.want, ",") {
+					t.Errorf("step[%v].processed[%v/%v]", i, processed, step.want)
+				}
+				if next <= 0 || next > w.wopts.Interval {
+					t.Errorf("step[%v].next[%v]", i, next)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchFoldersOptions(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		outDir string
+	}{
+		{"no output folder", ""},
+		{"output is the folder", dir},
+		{"output is the folder,relative", filepath.Join(dir, "sub", "..")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewFolderOptions("", "", GZip, false, 1)
+			opts.OutDir = tt.outDir
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := WatchFolders(ctx, dir, opts, &CCWatchOptions{Poll: true}); err == nil {
+				t.Errorf("WatchFolders.err.nil")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package cccompress
 import (
 	"bytes"
 	"compress/zlib"
+	"io"
 	"io/ioutil"
 )
 
@@ -47,6 +48,16 @@ func (p *CCZlib) Decompress(in []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+// NewWriter .
+func (p *CCZlib) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, p.CompressionLevel)
+}
+
+// NewReader .
+func (p *CCZlib) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
 // NewZlib .
 func NewZlib() *CCZlib {
 	return &CCZlib{
@@ -56,3 +67,16 @@ func NewZlib() *CCZlib {
 
 // DefaultZlib .
 var DefaultZlib = NewZlib()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:       Zlib,
+		Name:       "zlib",
+		HTTPCoding: "deflate", // HTTP "deflate" is the zlib format
+		Default:    func() Codec { return DefaultZlib },
+		New:        func(level int) Codec { return &CCZlib{CompressionLevel: level} },
+		MinLevel:   zlib.HuffmanOnly,
+		MaxLevel:   zlib.BestCompression,
+		Levels:     levelNames(zlib.BestSpeed, zlib.DefaultCompression, zlib.BestCompression),
+	})
+}
@@ -0,0 +1,97 @@
+package cccompress
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CCZstd .
+type CCZstd struct {
+	// CompressionLevel takes the 1~22 of the zstd command line,but the
+	// encoder only has 4 levels: 1~2 fastest,3~5 default,6~9 better and
+	// 10~22 best,see zstd.EncoderLevelFromZstd.
+	CompressionLevel int
+}
+
+var (
+	// encoders are safe for concurrent EncodeAll,keep one per level
+	zstdEncoders   sync.Map
+	zstdDecoder    *zstd.Decoder
+	zstdDecoderErr error
+	zstdOnce       sync.Once
+)
+
+// encoder .
+func (p *CCZstd) encoder() (*zstd.Encoder, error) {
+	if e, ok := zstdEncoders.Load(p.CompressionLevel); ok {
+		return e.(*zstd.Encoder), nil
+	}
+	e, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(p.CompressionLevel)))
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := zstdEncoders.LoadOrStore(p.CompressionLevel, e)
+	return actual.(*zstd.Encoder), nil
+}
+
+// Compress .
+func (p *CCZstd) Compress(in []byte) ([]byte, error) {
+	e, err := p.encoder()
+	if err != nil {
+		var out []byte
+		return out, err
+	}
+	return e.EncodeAll(in, nil), nil
+}
+
+// Decompress .
+func (p *CCZstd) Decompress(in []byte) ([]byte, error) {
+	zstdOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil)
+	})
+	if zstdDecoderErr != nil {
+		var out []byte
+		return out, zstdDecoderErr
+	}
+	return zstdDecoder.DecodeAll(in, nil)
+}
+
+// NewWriter .
+func (p *CCZstd) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(p.CompressionLevel)))
+}
+
+// NewReader .
+func (p *CCZstd) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// NewZstd .
+func NewZstd() *CCZstd {
+	return &CCZstd{
+		CompressionLevel: 3,
+	}
+}
+
+// DefaultZstd .
+var DefaultZstd = NewZstd()
+
+func init() {
+	RegisterCodec(&CCCodecInfo{
+		Mode:       Zstd,
+		Name:       "zstd",
+		Aliases:    []string{"zst"},
+		HTTPCoding: "zstd",
+		Default:    func() Codec { return DefaultZstd },
+		New:        func(level int) Codec { return &CCZstd{CompressionLevel: level} },
+		MinLevel:   1,
+		MaxLevel:   22,
+		Levels:     levelNames(1, 3, 19),
+	})
+}
@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"os"
 	"sort"
+
+	"CCServer.com/ccutility"
 )
 
 const (
@@ -116,14 +118,8 @@ func RemoveMetaData(dst string) error {
 
 	file.Close()
 
-	// 将结果写入输出文件
-	outFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("无法创建输出文件: %w", err)
-	}
-	defer outFile.Close()
-
-	_, err = output.WriteTo(outFile)
+	// 将结果原子写入输出文件
+	_, err = ccutility.WriteBinary(dst, output.Bytes())
 	if err != nil {
 		return fmt.Errorf("写入输出文件失败: %w", err)
 	}
@@ -136,13 +132,12 @@ func Convert(src, dst string, bgColor color.Color, decode func(file *os.File, ex
 	if img == nil {
 		return err
 	}
-	var out *os.File
-	out, err = os.Create(dst)
+	var out *ccutility.AtomicFile
+	out, err = ccutility.CreateAtomic(dst)
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
-	defer out.Close()
 
 	jpg := image.NewRGBA(image.Rect(0, 0, img.Bounds().Max.X, img.Bounds().Max.Y))
 
@@ -158,11 +153,13 @@ func Convert(src, dst string, bgColor color.Color, decode func(file *os.File, ex
 	}
 
 	// Encode to dest image format
-	return encode(out, jpg, &jpeg.Options{Quality: 80})
+	if err = encode(out.File, jpg, &jpeg.Options{Quality: 80}); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Commit("")
 }
 
-
-
 // ColorBox 表示一个颜色空间的范围
 type ColorBox struct {
 	Colors []color.Color // 包含的颜色
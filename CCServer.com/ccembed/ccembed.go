@@ -0,0 +1,149 @@
+// Package ccembed generates Go code shipping a folder inside the binary: the
+// folder is packed into a CC archive next to the generated file,which embeds
+// it with go:embed and serves it as an fs.FS decompressing files lazily.
+//
+// e.g. in package assets:
+//
+//	//go:generate CCCompress embed -o assets_cc.go -m zstd -k xxx.yyy ./static
+package ccembed
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// CCEmbedOptions .
+type CCEmbedOptions struct {
+	Package string // package of the generated file
+	Output  string // path of the generated .go file
+
+	// Archive is the file name of the archive,written in the folder of
+	// Output. Empty means the name of Output with .cca instead of .go.
+	Archive string
+
+	// Func is the name of the accessor,empty means FS.
+	Func string
+
+	// KeyParam makes the accessor take the obfuscation key,instead of
+	// keeping Folder.Key in the generated file.
+	KeyParam bool
+
+	// Folder selects the files and their codecs,see PackFolder.
+	Folder *cccompress.CCFolderOptions
+}
+
+// check fills the defaults and validates the names.
+func (p *CCEmbedOptions) check() error {
+	if p.Folder == nil {
+		return fmt.Errorf("CCEmbedOptions.Folder.nil")
+	}
+	if !token.IsIdentifier(p.Package) {
+		return fmt.Errorf("CCEmbedOptions.Package[%v].invalid", p.Package)
+	}
+	if !strings.HasSuffix(p.Output, ".go") {
+		return fmt.Errorf("CCEmbedOptions.Output[%v].not a .go file", p.Output)
+	}
+	if len(p.Func) == 0 {
+		p.Func = "FS"
+	}
+	if !token.IsIdentifier(p.Func) {
+		return fmt.Errorf("CCEmbedOptions.Func[%v].invalid", p.Func)
+	}
+	if len(p.Archive) == 0 {
+		p.Archive = strings.TrimSuffix(filepath.Base(p.Output), ".go") + ".cca"
+	}
+	// go:embed ignores names starting with . or _ and can't leave the package
+	if p.Archive != filepath.Base(p.Archive) || strings.HasPrefix(p.Archive, ".") || strings.HasPrefix(p.Archive, "_") {
+		return fmt.Errorf("CCEmbedOptions.Archive[%v].invalid,a file name not starting with . or _", p.Archive)
+	}
+	return nil
+}
+
+// Generate packs folders into the archive and writes the accessor to
+// opts.Output.
+func Generate(folders string, opts *CCEmbedOptions) (successed int64, err error) {
+	if opts == nil {
+		return 0, fmt.Errorf("Generate[%v].opts.nil", folders)
+	}
+	if err = opts.check(); err != nil {
+		return 0, fmt.Errorf("Generate[%v].err[%v]", folders, err)
+	}
+
+	src, err := generateSource(folders, opts)
+	if err != nil {
+		return 0, fmt.Errorf("Generate[%v].err[%v]", folders, err)
+	}
+	archivePath := filepath.Join(filepath.Dir(opts.Output), opts.Archive)
+	if successed, err = cccompress.PackFolder(folders, archivePath, opts.Folder); err != nil {
+		return 0, err
+	}
+	if _, err = ccutility.WriteBinary(opts.Output, src); err != nil {
+		return 0, fmt.Errorf("Generate[%v].err[%v]", opts.Output, err)
+	}
+	return successed, nil
+}
+
+// generateSource .
+func generateSource(folders string, opts *CCEmbedOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := sourceTemplate.Execute(buf, map[string]interface{}{
+		"Package":  opts.Package,
+		"Archive":  opts.Archive,
+		"Func":     opts.Func,
+		"Folder":   filepath.ToSlash(folders),
+		"KeyParam": opts.KeyParam,
+		"Key":      opts.Folder.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var sourceTemplate = template.Must(template.New("ccembed").Parse(`// Code generated by CCCompress embed; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	_ "embed"
+{{- if not .KeyParam}}
+	"sync"
+{{- end}}
+
+	"CCServer.com/cccompress"
+)
+
+//go:embed {{.Archive}}
+var cc{{.Func}}Archive []byte
+{{if .KeyParam}}
+// {{.Func}} returns the files of {{printf "%q" .Folder}},each one decompressed when
+// opened. key is the obfuscation key they were packed with.
+func {{.Func}}(key string) (*cccompress.CCFS, error) {
+	return cccompress.NewBytesFS(cc{{.Func}}Archive, key)
+}
+{{else}}
+const cc{{.Func}}Key = {{printf "%q" .Key}}
+
+var (
+	cc{{.Func}}Once sync.Once
+	cc{{.Func}}FS   *cccompress.CCFS
+	cc{{.Func}}Err  error
+)
+
+// {{.Func}} returns the files of {{printf "%q" .Folder}},each one decompressed when
+// opened.
+func {{.Func}}() (*cccompress.CCFS, error) {
+	cc{{.Func}}Once.Do(func() {
+		cc{{.Func}}FS, cc{{.Func}}Err = cccompress.NewBytesFS(cc{{.Func}}Archive, cc{{.Func}}Key)
+	})
+	return cc{{.Func}}FS, cc{{.Func}}Err
+}
+{{end}}`))
@@ -0,0 +1,100 @@
+package cchttp
+
+import (
+	"strconv"
+	"strings"
+
+	"CCServer.com/cccompress"
+)
+
+// ContentEncoding is the HTTP content coding of a compress mode,"" when the
+// mode has none.
+func ContentEncoding(mode byte) string {
+	if info := cccompress.CodecByMode(mode); info != nil {
+		return info.HTTPCoding
+	}
+	return ""
+}
+
+// ParseAcceptEncoding returns the q value of every coding of an
+// Accept-Encoding header,codings in lower case. A coding without q gets 1.
+func ParseAcceptEncoding(h string) map[string]float64 {
+	ret := map[string]float64{}
+	for _, item := range strings.Split(h, ",") {
+		params := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if len(coding) == 0 {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				} else {
+					q = 0
+				}
+			}
+		}
+		ret[coding] = q
+	}
+	return ret
+}
+
+// qValue is the q value of coding in accept,falling back to "*". identity
+// is acceptable unless refused explicitly.
+func qValue(accept map[string]float64, coding string) float64 {
+	if q, ok := accept[coding]; ok {
+		return q
+	}
+	if q, ok := accept["*"]; ok {
+		return q
+	}
+	if coding == "identity" {
+		return 1
+	}
+	return 0
+}
+
+// AcceptsEncoding reports whether an Accept-Encoding header h accepts coding.
+func AcceptsEncoding(h string, coding string) bool {
+	return qValue(ParseAcceptEncoding(h), strings.ToLower(coding)) > 0
+}
+
+// EncodingMode is the compress mode of an HTTP content coding,the reverse of
+// ContentEncoding.
+func EncodingMode(coding string) (byte, bool) {
+	coding = strings.ToLower(coding)
+	if coding == "x-gzip" {
+		coding = "gzip"
+	}
+	for _, info := range cccompress.Codecs() {
+		if len(info.HTTPCoding) > 0 && info.HTTPCoding == coding {
+			return info.Mode, true
+		}
+	}
+	return 0, false
+}
+
+// Negotiate picks the coding of offers with the highest q value in an
+// Accept-Encoding header h,the first offer wins a tie. It returns "" when
+// none is accepted,i.e. identity.
+func Negotiate(h string, offers []string) string {
+	if len(strings.TrimSpace(h)) == 0 {
+		return ""
+	}
+	accept := ParseAcceptEncoding(h)
+
+	var best string
+	var bestQ float64
+	for _, offer := range offers {
+		if q := qValue(accept, strings.ToLower(offer)); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,86 @@
+package cchttp
+
+import (
+	"testing"
+)
+
+func TestParseAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		h    string
+		want map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"gzip", map[string]float64{"gzip": 1}},
+		{"GZip, Deflate", map[string]float64{"gzip": 1, "deflate": 1}},
+		{"x-gzip", map[string]float64{"gzip": 1}},
+		{"br;q=0.8, gzip;q=0.5, *;q=0", map[string]float64{"br": 0.8, "gzip": 0.5, "*": 0}},
+		{"gzip; Q=0.3", map[string]float64{"gzip": 0.3}},
+		{"gzip;q=2", map[string]float64{"gzip": 0}},
+		{"gzip;q=abc", map[string]float64{"gzip": 0}},
+		{" , gzip ,", map[string]float64{"gzip": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.h, func(t *testing.T) {
+			got := ParseAcceptEncoding(tt.h)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseAcceptEncoding[%v/%v]", got, tt.want)
+			}
+			for coding, q := range tt.want {
+				if got[coding] != q {
+					t.Errorf("ParseAcceptEncoding[%v].q[%v/%v]", coding, got[coding], q)
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"br", "gzip", "deflate"}
+	tests := []struct {
+		name string
+		h    string
+		want string
+	}{
+		{"empty", "", ""},
+		{"one", "gzip", "gzip"},
+		{"first offer on a tie", "deflate, gzip, br", "br"},
+		{"highest q", "br;q=0.2, gzip;q=0.9, deflate;q=0.5", "gzip"},
+		{"refused", "br;q=0, gzip;q=0", ""},
+		{"not offered", "zstd", ""},
+		{"wildcard", "*", "br"},
+		{"wildcard refused", "*;q=0", ""},
+		{"wildcard but one", "br;q=0, *", "gzip"},
+		{"identity only", "identity", ""},
+		{"case", "GZIP", "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.h, offers); got != tt.want {
+				t.Errorf("Negotiate[%v].got[%v/%v]", tt.h, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		h      string
+		coding string
+		want   bool
+	}{
+		{"gzip", "gzip", true},
+		{"gzip", "GZIP", true},
+		{"gzip", "br", false},
+		{"*", "br", true},
+		{"*;q=0, gzip", "br", false},
+		{"", "identity", true},
+		{"identity;q=0", "identity", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.h+"/"+tt.coding, func(t *testing.T) {
+			if got := AcceptsEncoding(tt.h, tt.coding); got != tt.want {
+				t.Errorf("AcceptsEncoding[%v/%v]", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package cchttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// DefaultIndex is served for a folder.
+const DefaultIndex = "index.html"
+
+// CCFileServer serves a folder of compressed files. A file whose stored
+// codec has an HTTP content coding the client accepts is sent as stored with
+// Content-Encoding,otherwise it is decompressed on the fly. Range requests,
+// conditional requests and HEAD are handled by http.ServeContent.
+type CCFileServer struct {
+	Root  string
+	Key   string // obfuscation key of the files with a CC header,refused without it
+	Mode  byte   // compress mode of the files without CC header
+	Index string // file served for a folder,empty means no index
+
+	lock  sync.Mutex
+	etags map[string]*etagEntry
+}
+
+// etagEntry caches the hash of a file until it changes
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// NewFileServer .
+func NewFileServer(root string, key string, compressMode byte) *CCFileServer {
+	return &CCFileServer{
+		Root:  root,
+		Key:   key,
+		Mode:  compressMode,
+		Index: DefaultIndex,
+		etags: map[string]*etagEntry{},
+	}
+}
+
+// ServeHTTP .
+func (p *CCFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	filePath, fi, err := p.lookup(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("CCFileServer[%v].err[%v]", name, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err = p.serveFile(w, r, filePath, fi); err != nil {
+		log.Printf("CCFileServer[%v].err[%v]", filePath, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// lookup maps a clean url path to a file under Root.
+func (p *CCFileServer) lookup(name string) (string, os.FileInfo, error) {
+	filePath := filepath.Join(p.Root, filepath.FromSlash(name))
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	if fi.IsDir() {
+		if len(p.Index) == 0 {
+			return "", nil, os.ErrNotExist
+		}
+		filePath = filepath.Join(filePath, p.Index)
+		if fi, err = os.Stat(filePath); err != nil {
+			return "", nil, err
+		}
+		if fi.IsDir() {
+			return "", nil, os.ErrNotExist
+		}
+	}
+	return filePath, fi, nil
+}
+
+// serveFile .
+func (p *CCFileServer) serveFile(w http.ResponseWriter, r *http.Request, filePath string, fi os.FileInfo) error {
+	src, err := ccutility.ReadBinary(filePath)
+	if err != nil {
+		return err
+	}
+	// files without CC header are stored with Mode,even with a key
+	var header *cccompress.CCHeader
+	body := src
+	if _, hasHeader, _ := cccompress.Sniff(src); hasHeader {
+		if header, body, err = cccompress.Unwrap(p.Key, src); err != nil {
+			return err
+		}
+		// without a key the container would go out as the body
+		if header == nil {
+			return fmt.Errorf("CCHeader.key.empty")
+		}
+	}
+
+	mode := p.Mode
+	modTime := fi.ModTime()
+	if header != nil {
+		mode = header.Mode()
+		if t, ok := header.ModTime(); ok {
+			modTime = t
+		}
+	}
+
+	h := w.Header()
+	etag := p.etag(filePath, fi, src)
+	ctype := mime.TypeByExtension(filepath.Ext(filePath))
+	if mode != cccompress.Uncompressed {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	coding := ContentEncoding(mode)
+	if len(coding) > 0 && AcceptsEncoding(r.Header.Get("Accept-Encoding"), coding) {
+		// the stored body as is,the client decodes it
+		if len(ctype) == 0 {
+			ctype = "application/octet-stream"
+		}
+		h.Set("Content-Type", ctype)
+		h.Set("Content-Encoding", coding)
+		h.Set("ETag", fmt.Sprintf(`"%v-%v"`, etag, coding))
+		http.ServeContent(w, r, filePath, modTime, bytes.NewReader(body))
+		return nil
+	}
+
+	dst := body
+	if mode != cccompress.Uncompressed {
+		info := cccompress.CodecByMode(mode)
+		if info == nil {
+			return fmt.Errorf("mode[%v].unknown", mode)
+		}
+		if dst, err = info.Default().Decompress(body); err != nil {
+			return err
+		}
+	}
+	if header != nil {
+		if err = header.Check(dst); err != nil {
+			return err
+		}
+	}
+	if len(ctype) > 0 {
+		h.Set("Content-Type", ctype)
+	}
+	h.Set("ETag", fmt.Sprintf(`"%v"`, etag))
+	http.ServeContent(w, r, filePath, modTime, bytes.NewReader(dst))
+	return nil
+}
+
+// etag is the hash of the stored file,kept until its size or mtime change.
+func (p *CCFileServer) etag(filePath string, fi os.FileInfo, src []byte) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.etags == nil {
+		p.etags = map[string]*etagEntry{}
+	}
+
+	e := p.etags[filePath]
+	if e != nil && e.size == fi.Size() && e.modTime.Equal(fi.ModTime()) {
+		return e.etag
+	}
+	sum := sha256.Sum256(src)
+	e = &etagEntry{size: fi.Size(), modTime: fi.ModTime(), etag: hex.EncodeToString(sum[:16])}
+	p.etags[filePath] = e
+	return e.etag
+}
@@ -0,0 +1,155 @@
+package cchttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"CCServer.com/cccompress"
+)
+
+func TestFileServer(t *testing.T) {
+	plain := strings.Repeat("<p>served</p>", 100)
+	dir := t.TempDir()
+	files := map[string]struct {
+		key  string
+		mode byte
+	}{
+		"keyed.html":          {"xxx.yyy", cccompress.GZip},
+		"raw.html":            {"", cccompress.GZip},
+		"keyed.json":          {"xxx.yyy", cccompress.Bz2},
+		"sub/" + DefaultIndex: {"xxx.yyy", cccompress.Zstd},
+	}
+	for name, f := range files {
+		src, err := cccompress.CompressWithOptions(f.key, []byte(plain), &cccompress.CCOptions{Mode: f.mode, Checksum: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filePath, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		path     string
+		accept   string
+		status   int
+		encoding string // expected Content-Encoding
+	}{
+		{"stored", "xxx.yyy", "/keyed.html", "gzip", http.StatusOK, "gzip"},
+		{"decoded", "xxx.yyy", "/keyed.html", "", http.StatusOK, ""},
+		{"refused coding", "xxx.yyy", "/keyed.html", "gzip;q=0, br", http.StatusOK, ""},
+		{"no coding", "xxx.yyy", "/keyed.json", "gzip, br, zstd", http.StatusOK, ""},
+		{"index", "xxx.yyy", "/sub/", "zstd", http.StatusOK, "zstd"},
+		{"without header", "xxx.yyy", "/raw.html", "", http.StatusOK, ""},
+		{"without header,no key", "", "/raw.html", "gzip", http.StatusOK, "gzip"},
+		{"header,no key", "", "/keyed.html", "gzip", http.StatusInternalServerError, ""},
+		{"header,no key,decoded", "", "/keyed.html", "", http.StatusInternalServerError, ""},
+		{"missing", "xxx.yyy", "/missing.html", "", http.StatusNotFound, ""},
+		{"no index", "xxx.yyy", "/", "", http.StatusNotFound, ""},
+		{"escaping the root", "xxx.yyy", "/../keyed.html", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tt.path
+			if len(tt.accept) > 0 {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			NewFileServer(dir, tt.key, cccompress.GZip).ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status[%v/%v]", rec.Code, tt.status)
+			}
+			if coding := rec.Header().Get("Content-Encoding"); coding != tt.encoding {
+				t.Fatalf("Content-Encoding[%v/%v]", coding, tt.encoding)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			got := rec.Body.Bytes()
+			if len(tt.encoding) > 0 {
+				mode, _ := EncodingMode(tt.encoding)
+				spec := cccompress.CodecSpec{Mode: mode}
+				codec, err := spec.Codec()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err = codec.Decompress(got); err != nil {
+					t.Fatalf("Decompress.err[%v]", err)
+				}
+			}
+			if string(got) != plain {
+				t.Errorf("body[%v/%v].no match", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestFileServerConditional(t *testing.T) {
+	dir := t.TempDir()
+	src, err := cccompress.CompressWithOptions("xxx.yyy", []byte("0123456789"), &cccompress.CCOptions{Mode: cccompress.GZip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "a.txt"), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := NewFileServer(dir, "xxx.yyy", cccompress.GZip)
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		fs.ServeHTTP(rec, r)
+		return rec
+	}
+
+	first := get(nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || len(etag) == 0 || first.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("status[%v].etag[%v].vary[%v]", first.Code, etag, first.Header().Get("Vary"))
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{"not modified", map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{"other etag", map[string]string{"If-None-Match": `"other"`}, http.StatusOK, "0123456789"},
+		{"range", map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "234"},
+		{"coded etag", map[string]string{"If-None-Match": etag, "Accept-Encoding": "gzip"}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.header)
+			if rec.Code != tt.status {
+				t.Fatalf("status[%v/%v]", rec.Code, tt.status)
+			}
+			if len(tt.body) > 0 && rec.Body.String() != tt.body {
+				t.Errorf("body[%q/%q]", rec.Body.String(), tt.body)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/a.txt", nil)
+	rec := httptest.NewRecorder()
+	fs.ServeHTTP(rec, r)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST.status[%v]", rec.Code)
+	}
+}
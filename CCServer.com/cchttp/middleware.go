@@ -0,0 +1,310 @@
+package cchttp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"CCServer.com/cccompress"
+)
+
+// DefaultMinSize is the smallest response compressed by default.
+const DefaultMinSize = 1024
+
+// DefaultContentTypes are compressed by default,an entry ending with "/"
+// matches every subtype.
+var DefaultContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CCCompressOptions .
+type CCCompressOptions struct {
+	// Specs are the codecs offered,in order of preference. Only the ones
+	// with an HTTP content coding are used. Empty means gzip and deflate.
+	Specs []cccompress.CodecSpec
+
+	// MinSize is the smallest body compressed,0 means DefaultMinSize and a
+	// negative one compresses every body.
+	MinSize int
+
+	// ContentTypes allowed,nil means DefaultContentTypes.
+	ContentTypes []string
+}
+
+// codings returns the content coding of every spec.
+func (p *CCCompressOptions) codings() ([]string, map[string]cccompress.CodecSpec) {
+	specs := p.Specs
+	if len(specs) == 0 {
+		specs = []cccompress.CodecSpec{{Mode: cccompress.GZip}, {Mode: cccompress.Zlib}}
+	}
+
+	var offers []string
+	bySpec := map[string]cccompress.CodecSpec{}
+	for _, spec := range specs {
+		coding := ContentEncoding(spec.Mode)
+		if len(coding) == 0 {
+			continue
+		}
+		if _, ok := bySpec[coding]; !ok {
+			offers = append(offers, coding)
+			bySpec[coding] = spec
+		}
+	}
+	return offers, bySpec
+}
+
+// allowed reports whether a Content-Type is in the allowlist.
+func (p *CCCompressOptions) allowed(ctype string) bool {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+	types := p.ContentTypes
+	if types == nil {
+		types = DefaultContentTypes
+	}
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if strings.HasSuffix(t, "/") {
+			if strings.HasPrefix(mediaType, t) {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Compress wraps h so its responses are compressed with the codec of opts the
+// client prefers. A body is compressed when it is at least MinSize bytes and
+// its Content-Type is allowed,responses that already have a Content-Encoding,
+// partial content and bodiless ones are left alone. opts may be nil.
+func Compress(h http.Handler, opts *CCCompressOptions) http.Handler {
+	if opts == nil {
+		opts = &CCCompressOptions{}
+	}
+	offers, bySpec := opts.codings()
+	minSize := opts.MinSize
+	if minSize == 0 {
+		minSize = DefaultMinSize
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := Negotiate(r.Header.Get("Accept-Encoding"), offers)
+		cw := &compressWriter{
+			ResponseWriter: w,
+			opts:           opts,
+			coding:         coding,
+			spec:           bySpec[coding],
+			minSize:        minSize,
+			head:           r.Method == http.MethodHead,
+		}
+		defer func() {
+			if err := cw.Close(); err != nil {
+				log.Printf("Compress[%v].err[%v]", r.URL.Path, err)
+			}
+		}()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds the body back until it knows whether to compress it:
+// MinSize bytes were written,the handler flushed or returned.
+type compressWriter struct {
+	http.ResponseWriter
+	opts    *CCCompressOptions
+	coding  string // negotiated coding,"" for identity
+	spec    cccompress.CodecSpec
+	minSize int
+	head    bool
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	writer  io.WriteCloser // nil when the body is sent as is
+}
+
+// WriteHeader .
+func (p *compressWriter) WriteHeader(status int) {
+	if p.decided || p.status != 0 {
+		return
+	}
+	if status < 200 {
+		// informational responses go through
+		p.ResponseWriter.WriteHeader(status)
+		return
+	}
+	p.status = status
+}
+
+// Write .
+func (p *compressWriter) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.status = http.StatusOK
+	}
+	if !p.decided {
+		p.buf.Write(b)
+		if p.buf.Len() < p.minSize {
+			return len(b), nil
+		}
+		if err := p.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if p.writer != nil {
+		return p.writer.Write(b)
+	}
+	return p.ResponseWriter.Write(b)
+}
+
+// Flush sends what was written so far,compressing it if the body was known
+// to be compressed.
+func (p *compressWriter) Flush() {
+	if !p.decided {
+		if p.status == 0 {
+			p.status = http.StatusOK
+		}
+		if err := p.decide(); err != nil {
+			log.Printf("compressWriter.Flush.err[%v]", err)
+			return
+		}
+	}
+	if f, ok := p.writer.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			log.Printf("compressWriter.Flush.err[%v]", err)
+			return
+		}
+	}
+	if f, ok := p.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack .
+func (p *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := p.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("compressWriter.Hijack.not supported")
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (p *compressWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// Close decides with what was written when it was less than MinSize and ends
+// the compressed stream.
+func (p *compressWriter) Close() error {
+	if !p.decided {
+		if p.status == 0 {
+			if p.buf.Len() == 0 {
+				// the handler wrote nothing,let net/http answer
+				return nil
+			}
+			p.status = http.StatusOK
+		}
+		if err := p.decide(); err != nil {
+			return err
+		}
+	}
+	if p.writer != nil {
+		return p.writer.Close()
+	}
+	return nil
+}
+
+// decide writes the header,compressed or not,then the buffered body.
+func (p *compressWriter) decide() error {
+	p.decided = true
+	h := p.Header()
+
+	eligible := p.eligible()
+	if eligible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if !eligible || len(p.coding) == 0 || p.size() < p.minSize {
+		p.ResponseWriter.WriteHeader(p.status)
+		_, err := p.ResponseWriter.Write(p.buf.Bytes())
+		p.buf.Reset()
+		return err
+	}
+
+	codec, err := p.spec.Codec()
+	if err != nil {
+		return err
+	}
+	sc, ok := codec.(cccompress.StreamCodec)
+	if !ok {
+		return fmt.Errorf("compressWriter.mode[%v].not a StreamCodec", p.spec.Mode)
+	}
+
+	h.Set("Content-Encoding", p.coding)
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	if etag := h.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		// not byte for byte the same any more,a weak one still matches
+		// If-None-Match
+		h.Set("ETag", "W/"+etag)
+	}
+	p.ResponseWriter.WriteHeader(p.status)
+	if p.head {
+		p.buf.Reset()
+		return nil
+	}
+
+	if p.writer, err = sc.NewWriter(p.ResponseWriter); err != nil {
+		return err
+	}
+	_, err = p.writer.Write(p.buf.Bytes())
+	p.buf.Reset()
+	return err
+}
+
+// size is the length of the body,from Content-Length for HEAD.
+func (p *compressWriter) size() int {
+	if p.head {
+		if n, err := strconv.Atoi(p.Header().Get("Content-Length")); err == nil {
+			return n
+		}
+	}
+	return p.buf.Len()
+}
+
+// eligible reports whether the response may be compressed,whatever the
+// client accepts.
+func (p *compressWriter) eligible() bool {
+	switch p.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	h := p.Header()
+	if len(h.Get("Content-Encoding")) > 0 || len(h.Get("Content-Range")) > 0 {
+		return false
+	}
+	if strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	ctype := h.Get("Content-Type")
+	if len(ctype) == 0 {
+		// as net/http would
+		ctype = http.DetectContentType(p.buf.Bytes())
+		h.Set("Content-Type", ctype)
+	}
+	return p.opts.allowed(ctype)
+}
@@ -0,0 +1,125 @@
+package cchttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"CCServer.com/cccompress"
+)
+
+func TestContentEncoding(t *testing.T) {
+	tests := []struct {
+		mode   byte
+		coding string
+	}{
+		{cccompress.GZip, "gzip"},
+		{cccompress.Zlib, "deflate"},
+		{cccompress.Brotli, "br"},
+		{cccompress.Zstd, "zstd"},
+		{cccompress.Bz2, ""},
+		{cccompress.Uncompressed, ""},
+		{0xFF, ""},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.mode)), func(t *testing.T) {
+			if got := ContentEncoding(tt.mode); got != tt.coding {
+				t.Errorf("ContentEncoding[%v/%v]", got, tt.coding)
+			}
+			if len(tt.coding) == 0 {
+				return
+			}
+			if mode, ok := EncodingMode(strings.ToUpper(tt.coding)); !ok || mode != tt.mode {
+				t.Errorf("EncodingMode[%v].mode[%v/%v]", tt.coding, mode, tt.mode)
+			}
+		})
+	}
+	if mode, ok := EncodingMode("x-gzip"); !ok || mode != cccompress.GZip {
+		t.Errorf("EncodingMode[x-gzip].mode[%v]", mode)
+	}
+	if _, ok := EncodingMode("compress"); ok {
+		t.Errorf("EncodingMode[compress].ok")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compress me,", 200)
+
+	tests := []struct {
+		name     string
+		accept   string
+		specs    []cccompress.CodecSpec
+		ctype    string
+		body     string
+		status   int
+		header   map[string]string
+		encoding string // expected Content-Encoding
+	}{
+		{"gzip", "gzip", nil, "text/plain", body, 200, nil, "gzip"},
+		{"deflate", "deflate", nil, "text/plain", body, 200, nil, "deflate"},
+		{"preferred", "gzip;q=0.5, deflate", nil, "text/plain", body, 200, nil, "deflate"},
+		{"first offer on a tie", "deflate, gzip", nil, "text/plain", body, 200, nil, "gzip"},
+		{"zstd offered", "gzip;q=0.8, zstd", []cccompress.CodecSpec{{Mode: cccompress.GZip}, {Mode: cccompress.Zstd}}, "application/json", body, 200, nil, "zstd"},
+		{"br offered", "br", []cccompress.CodecSpec{{Mode: cccompress.Brotli, Level: 5, HasLevel: true}}, "text/html", body, 200, nil, "br"},
+		{"not offered", "br", nil, "text/plain", body, 200, nil, ""},
+		{"no accept", "", nil, "text/plain", body, 200, nil, ""},
+		{"refused", "gzip;q=0", nil, "text/plain", body, 200, nil, ""},
+		{"small body", "gzip", nil, "text/plain", "tiny", 200, nil, ""},
+		{"image", "gzip", nil, "image/png", body, 200, nil, ""},
+		{"detected type", "gzip", nil, "", body, 200, nil, "gzip"},
+		{"already encoded", "gzip", nil, "text/plain", body, 200, map[string]string{"Content-Encoding": "br"}, "br"},
+		{"no-transform", "gzip", nil, "text/plain", body, 200, map[string]string{"Cache-Control": "no-transform"}, ""},
+		{"partial content", "gzip", nil, "text/plain", body, http.StatusPartialContent, map[string]string{"Content-Range": "bytes 0-9/100"}, ""},
+		{"error status", "gzip", nil, "text/plain", body, http.StatusNotFound, nil, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(tt.ctype) > 0 {
+					w.Header().Set("Content-Type", tt.ctype)
+				}
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				// in pieces,to go through the buffering
+				for i := 0; i < len(tt.body); i += 100 {
+					w.Write([]byte(tt.body[i:min(i+100, len(tt.body))]))
+				}
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(tt.accept) > 0 {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			Compress(h, &CCCompressOptions{Specs: tt.specs}).ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status[%v/%v]", rec.Code, tt.status)
+			}
+			coding := rec.Header().Get("Content-Encoding")
+			if coding != tt.encoding {
+				t.Fatalf("Content-Encoding[%v/%v]", coding, tt.encoding)
+			}
+			got := rec.Body.Bytes()
+			if mode, ok := EncodingMode(coding); ok && len(tt.header["Content-Encoding"]) == 0 {
+				if rec.Header().Get("Vary") != "Accept-Encoding" {
+					t.Errorf("Vary[%v]", rec.Header().Get("Vary"))
+				}
+				spec := cccompress.CodecSpec{Mode: mode}
+				codec, err := spec.Codec()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err = codec.Decompress(got); err != nil {
+					t.Fatalf("Decompress.err[%v]", err)
+				}
+			}
+			if string(got) != tt.body {
+				t.Errorf("body[%v/%v].no match", len(got), len(tt.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package ccutility
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a temp file in the same directory as its target.
+// Nothing is visible at the target path until Commit succeeds.
+type AtomicFile struct {
+	*os.File
+	path string
+}
+
+// CreateAtomic .
+func CreateAtomic(filePath string) (*AtomicFile, error) {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("CreateAtomic.MkdirAll[%v].err[%v]", dir, err)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("CreateAtomic.CreateTemp[%v].err[%v]", filePath, err)
+	}
+
+	// keep the permissions of the file we are replacing
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filePath); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("CreateAtomic.Chmod[%v].err[%v]", f.Name(), err)
+	}
+
+	return &AtomicFile{File: f, path: filePath}, nil
+}
+
+// Commit flushes the temp file to disk and renames it over the target.
+// If backupPath isn't empty the existing target is kept there, but only
+// once the new content is safely on disk.
+func (p *AtomicFile) Commit(backupPath string) error {
+	tmp := p.File.Name()
+
+	if err := p.File.Sync(); err != nil {
+		p.Abort()
+		return fmt.Errorf("AtomicFile.Sync[%v].err[%v]", p.path, err)
+	}
+	if err := p.File.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("AtomicFile.Close[%v].err[%v]", p.path, err)
+	}
+
+	if len(backupPath) > 0 {
+		if err := backup(p.path, backupPath); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp, p.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("AtomicFile.Rename[%v].err[%v]", p.path, err)
+	}
+
+	syncDir(filepath.Dir(p.path))
+	return nil
+}
+
+// Abort discards the temp file and leaves the target untouched.
+func (p *AtomicFile) Abort() error {
+	p.File.Close()
+	return os.Remove(p.File.Name())
+}
+
+// backup keeps the current content of filePath at backupPath.
+// A hard link keeps filePath in place until the final rename; filesystems
+// without links fall back to a copy.
+func backup(filePath string, backupPath string) error {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("backup.Remove[%v].err[%v]", backupPath, err)
+	}
+	if err := os.Link(filePath, backupPath); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("backup.Open[%v].err[%v]", filePath, err)
+	}
+	defer src.Close()
+
+	dst, err := CreateAtomic(backupPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Abort()
+		return fmt.Errorf("backup.Copy[%v].err[%v]", backupPath, err)
+	}
+	return dst.Commit("")
+}
+
+// syncDir makes a rename durable. Not every platform can fsync a directory,
+// so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
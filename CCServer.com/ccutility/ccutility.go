@@ -2,7 +2,9 @@ package ccutility
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -113,33 +115,31 @@ func ReadBinary(filePath string) ([]byte, error) {
 
 // WriteBinary .
 func WriteBinary(filePath string, src []byte) (int64, error) {
-	path := strings.Split(filePath, "/")
-	dirs := strings.Replace(strings.Trim(fmt.Sprint(path[:len(path)-1]), "[]"), " ", "/", -1) + "/"
-	name := path[len(path)-1]
-
-	err := os.MkdirAll(dirs, os.ModePerm)
-	if err != nil {
-		log.Printf("WriteBinary.MkdirAll[%v].err[%v]", dirs, err)
-		return 0, fmt.Errorf("WriteBinary.MkdirAll[%v].err[%v]", dirs, err)
-	}
+	return WriteBinaryWithBackup(filePath, src, "")
+}
 
-	fs, err := os.Create(dirs + name)
+// WriteBinaryWithBackup writes src to a temp file beside filePath, fsyncs it
+// and renames it over filePath. The old content is kept at backupPath (if not
+// empty) only after the new content has been written successfully.
+func WriteBinaryWithBackup(filePath string, src []byte, backupPath string) (int64, error) {
+	fs, err := CreateAtomic(filePath)
 	if err != nil {
-		log.Printf("WriteBinary.Create[%v].err[%v]", dirs+name, err)
-		return 0, fmt.Errorf("WriteBinary.Create[%v].err[%v]", dirs+name, err)
+		log.Printf("WriteBinary.Create[%v].err[%v]", filePath, err)
+		return 0, fmt.Errorf("WriteBinary.Create[%v].err[%v]", filePath, err)
 	}
 
 	var dlen int64
 	dlen, err = io.Copy(fs, bytes.NewReader(src))
 	if err != nil {
-		log.Printf("WriteBinary.Copy[%v].err[%v]", dirs+name, err)
-		return 0, fmt.Errorf("WriteBinary.Copy[%v].err[%v]", dirs+name, err)
+		fs.Abort()
+		log.Printf("WriteBinary.Copy[%v].err[%v]", filePath, err)
+		return 0, fmt.Errorf("WriteBinary.Copy[%v].err[%v]", filePath, err)
 	}
 
-	err = fs.Close()
+	err = fs.Commit(backupPath)
 	if err != nil {
-		log.Printf("WriteBinary.Close[%v].err[%v]", dirs+name, err)
-		return 0, fmt.Errorf("WriteBinary.Close[%v].err[%v]", dirs+name, err)
+		log.Printf("WriteBinary.Commit[%v].err[%v]", filePath, err)
+		return 0, fmt.Errorf("WriteBinary.Commit[%v].err[%v]", filePath, err)
 	}
 
 	return dlen, nil
@@ -156,3 +156,25 @@ func Int64ToBytes(i int64) []byte {
 func BytesToInt64(buf []byte) int64 {
 	return int64(binary.BigEndian.Uint64(buf))
 }
+
+// HashFile returns the hex sha256 and the size of a file.
+func HashFile(filePath string) (string, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("HashFile[%v].Open.err[%v]", filePath, err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("HashFile[%v].Read.err[%v]", filePath, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// HashBinary returns the hex sha256 of src.
+func HashBinary(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,26 @@
+package ccutility
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ApplyFileInfo gives filePath the permissions and modification time of the
+// original file,and its owner where the platform allows it.
+func ApplyFileInfo(filePath string, mode os.FileMode, modTime time.Time) error {
+	if err := os.Chmod(filePath, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return fmt.Errorf("ApplyFileInfo[%v].Chmod.err[%v]", filePath, err)
+	}
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		return fmt.Errorf("ApplyFileInfo[%v].Chtimes.err[%v]", filePath, err)
+	}
+	return nil
+}
+
+// CopyFileInfo applies fi to filePath,owner included. The owner goes first,
+// a chown clears the setuid/setgid bits the mode then puts back.
+func CopyFileInfo(filePath string, fi os.FileInfo) error {
+	copyOwner(filePath, fi)
+	return ApplyFileInfo(filePath, fi.Mode(), fi.ModTime())
+}
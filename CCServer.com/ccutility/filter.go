@@ -0,0 +1,248 @@
+package ccutility
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultIgnoreFile .
+const DefaultIgnoreFile = ".ccignore"
+
+// FileFilter selects files during a folder traversal.
+// Patterns are doublestar globs matched against the slash separated path
+// relative to the traversal root,e.g. "textures/**/*.png".
+type FileFilter struct {
+	Exts       []string // suffixes,case insensitive. empty matches every file
+	Include    []string // empty includes every file
+	Exclude    []string // a matching folder is not entered at all
+	MinSize    int64    // 0 means no limit
+	MaxSize    int64    // 0 means no limit
+	IgnoreFile string   // name of the ignore files to honour,empty to disable
+}
+
+// NewFileFilter .
+func NewFileFilter(ext string) *FileFilter {
+	return &FileFilter{
+		Exts:       SplitList(ext),
+		IgnoreFile: DefaultIgnoreFile,
+	}
+}
+
+// SplitList splits a comma separated list,dropping empty items.
+func SplitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// Validate checks every pattern of the filter.
+func (p *FileFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Include...), p.Exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("FileFilter.pattern[%v].invalid", pattern)
+		}
+	}
+	if p.MaxSize > 0 && p.MinSize > p.MaxSize {
+		return fmt.Errorf("FileFilter.size[%v/%v].invalid", p.MinSize, p.MaxSize)
+	}
+	return nil
+}
+
+// MatchName checks the name based rules (extensions,include,exclude) of a
+// file whose path relative to the root is rel.
+func (p *FileFilter) MatchName(rel string) bool {
+	if len(p.Exts) > 0 {
+		found := false
+		name := strings.ToLower(rel)
+		for _, ext := range p.Exts {
+			if strings.HasSuffix(name, strings.ToLower(ext)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if matchAny(p.Exclude, rel) {
+		return false
+	}
+	if len(p.Include) > 0 && !matchAny(p.Include, rel) {
+		return false
+	}
+	return true
+}
+
+// MatchSize .
+func (p *FileFilter) MatchSize(size int64) bool {
+	if p.MinSize > 0 && size < p.MinSize {
+		return false
+	}
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return false
+	}
+	return true
+}
+
+// matchAny .
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRule is one line of an ignore file.
+type ignoreRule struct {
+	base    string // folder of the ignore file,relative to the root
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// match .
+func (p *ignoreRule) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if len(p.base) > 0 {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		rel = rel[len(p.base)+1:]
+	}
+	ok, _ := doublestar.Match(p.pattern, rel)
+	return ok
+}
+
+// ignored applies the rules in order,the last matching rule wins.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	ret := false
+	for i := range rules {
+		if rules[i].match(rel, isDir) {
+			ret = !rules[i].negate
+		}
+	}
+	return ret
+}
+
+// loadIgnoreFile reads a gitignore like file: one pattern per line,'#' starts
+// a comment,'!' re-includes,a trailing '/' matches folders only and a pattern
+// without '/' matches at any depth.
+func loadIgnoreFile(filePath string, base string) ([]ignoreRule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if !doublestar.ValidatePattern(line) {
+			log.Printf("loadIgnoreFile[%v].pattern[%v].invalid", filePath, line)
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// GetAllFileByFilter returns the files under pathname selected by filter,
+// named the same way as GetAllFileByExt does.
+func GetAllFileByFilter(pathname string, filter *FileFilter, s []string) ([]string, error) {
+	if filter == nil {
+		filter = NewFileFilter("")
+	}
+	if err := filter.Validate(); err != nil {
+		return s, err
+	}
+	return walkFilter(pathname, "", filter, nil, s)
+}
+
+// walkFilter .
+func walkFilter(pathname string, rel string, filter *FileFilter, rules []ignoreRule, s []string) ([]string, error) {
+	rd, err := os.ReadDir(pathname)
+	if err != nil {
+		log.Printf("GetAllFileByFilter.ReadDir[%v].err[%v]", pathname, err)
+		return s, err
+	}
+
+	if len(filter.IgnoreFile) > 0 {
+		more, err := loadIgnoreFile(pathname+"/"+filter.IgnoreFile, rel)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("GetAllFileByFilter.IgnoreFile[%v].err[%v]", pathname, err)
+			return s, err
+		}
+		// copy so sibling folders don't see each other's rules
+		rules = append(rules[:len(rules):len(rules)], more...)
+	}
+
+	for _, fi := range rd {
+		name := fi.Name()
+		fullName := pathname + "/" + name
+		relName := path.Join(rel, name)
+
+		if fi.IsDir() {
+			if ignored(rules, relName, true) || matchAny(filter.Exclude, relName) {
+				continue
+			}
+			s, err = walkFilter(fullName, relName, filter, rules, s)
+			if err != nil {
+				log.Printf("GetAllFileByFilter[%v].err[%v]", fullName, err)
+				return s, err
+			}
+			continue
+		}
+
+		if name == filter.IgnoreFile || ignored(rules, relName, false) || !filter.MatchName(relName) {
+			continue
+		}
+
+		if filter.MinSize > 0 || filter.MaxSize > 0 {
+			info, err := fi.Info()
+			if err != nil {
+				return s, err
+			}
+			if !filter.MatchSize(info.Size()) {
+				continue
+			}
+		}
+		s = append(s, fullName)
+	}
+	return s, nil
+}
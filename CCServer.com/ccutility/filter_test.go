@@ -0,0 +1,127 @@
+package ccutility
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFileFilterMatchName(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter FileFilter
+		rel    string
+		want   bool
+	}{
+		{"no rule", FileFilter{}, "a/b.png", true},
+		{"ext", FileFilter{Exts: []string{"png"}}, "a/b.png", true},
+		{"ext case", FileFilter{Exts: []string{".PNG"}}, "a/b.png", true},
+		{"other ext", FileFilter{Exts: []string{"png", "jpg"}}, "a/b.txt", false},
+		{"include", FileFilter{Include: []string{"textures/**/*.png"}}, "textures/x/y.png", true},
+		{"not included", FileFilter{Include: []string{"textures/**/*.png"}}, "ui/y.png", false},
+		{"exclude", FileFilter{Exclude: []string{"**/*.tmp"}}, "a/b.tmp", false},
+		{"exclude wins", FileFilter{Include: []string{"**"}, Exclude: []string{"a/**"}}, "a/b.png", false},
+		{"ext then include", FileFilter{Exts: []string{"png"}, Include: []string{"a/*"}}, "a/b.png", true},
+		{"ext but not include", FileFilter{Exts: []string{"png"}, Include: []string{"c/*"}}, "a/b.png", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.MatchName(tt.rel); got != tt.want {
+				t.Errorf("MatchName[%v].got[%v/%v]", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFilterValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter FileFilter
+		ok     bool
+	}{
+		{"empty", FileFilter{}, true},
+		{"globs", FileFilter{Include: []string{"**/*.png"}, Exclude: []string{"{a,b}/*"}}, true},
+		{"bad include", FileFilter{Include: []string{"[a"}}, false},
+		{"bad exclude", FileFilter{Exclude: []string{"a/{b"}}, false},
+		{"sizes", FileFilter{MinSize: 10, MaxSize: 20}, true},
+		{"min over max", FileFilter{MinSize: 30, MaxSize: 20}, false},
+		{"min only", FileFilter{MinSize: 30}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate.err[%v]", err)
+			}
+		})
+	}
+}
+
+func TestGetAllFileByFilter(t *testing.T) {
+	files := map[string]string{
+		"a.png":           "0123456789",
+		"a.txt":           "01",
+		"build/out.png":   "0123456789",
+		"keep/x.png":      "0123456789",
+		"keep/x.tmp":      "0123456789",
+		"keep/deep/y.png": "0123456789",
+		"keep/deep/z.log": "0123456789",
+	}
+
+	tests := []struct {
+		name   string
+		ignore map[string]string // ignore files,folder -> content
+		filter *FileFilter
+		want   []string
+	}{
+		{"every file", nil, nil, []string{"a.png", "a.txt", "build/out.png", "keep/deep/y.png", "keep/deep/z.log", "keep/x.png", "keep/x.tmp"}},
+		{"ext", nil, NewFileFilter("png"), []string{"a.png", "build/out.png", "keep/deep/y.png", "keep/x.png"}},
+		{"exclude folder", nil, &FileFilter{Exclude: []string{"keep"}}, []string{"a.png", "a.txt", "build/out.png"}},
+		{"sizes", nil, &FileFilter{MinSize: 5}, []string{"a.png", "build/out.png", "keep/deep/y.png", "keep/deep/z.log", "keep/x.png", "keep/x.tmp"}},
+		{"ignore file", map[string]string{"": "# comment\n*.tmp\nbuild/\n"}, NewFileFilter(""), []string{"a.png", "a.txt", "keep/deep/y.png", "keep/deep/z.log", "keep/x.png"}},
+		{"anchored", map[string]string{"": "/a.png\n"}, NewFileFilter("png"), []string{"build/out.png", "keep/deep/y.png", "keep/x.png"}},
+		{"negate", map[string]string{"": "*.png\n!keep/**/y.png\n"}, NewFileFilter(""), []string{"a.txt", "keep/deep/y.png", "keep/deep/z.log", "keep/x.tmp"}},
+		{"nested ignore file", map[string]string{"keep": "deep/\n"}, NewFileFilter(""), []string{"a.png", "a.txt", "build/out.png", "keep/x.png", "keep/x.tmp"}},
+		{"nested rules stay below", map[string]string{"keep": "*.png\n"}, NewFileFilter(""), []string{"a.png", "a.txt", "build/out.png", "keep/deep/z.log", "keep/x.tmp"}},
+		{"ignore file disabled", map[string]string{"": "*\n"}, &FileFilter{Exts: []string{"png"}}, []string{"a.png", "build/out.png", "keep/deep/y.png", "keep/x.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range files {
+				writeFilterFile(t, filepath.Join(dir, filepath.FromSlash(name)), data)
+			}
+			for folder, data := range tt.ignore {
+				writeFilterFile(t, filepath.Join(dir, filepath.FromSlash(folder), DefaultIgnoreFile), data)
+			}
+
+			got, err := GetAllFileByFilter(dir, tt.filter, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				rel, err := filepath.Rel(dir, got[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[i] = filepath.ToSlash(rel)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetAllFileByFilter[%v/%v]", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFilterFile .
+func writeFilterFile(t *testing.T, filePath string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
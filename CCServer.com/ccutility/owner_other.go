@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ccutility
+
+import "os"
+
+// copyOwner .
+func copyOwner(filePath string, fi os.FileInfo) {}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package ccutility
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner is best effort: only root may give a file away.
+func copyOwner(filePath string, fi os.FileInfo) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if int(st.Uid) == os.Getuid() && int(st.Gid) == os.Getgid() {
+		return
+	}
+	os.Lchown(filePath, int(st.Uid), int(st.Gid))
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package ccutility
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCopyFileInfo(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("giving a file away needs root")
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		uid  int
+		mode os.FileMode
+	}{
+		{"same owner", 0, 0640},
+		{"other owner", 1234, 0640},
+		{"setuid,same owner", 0, os.ModeSetuid | 0755},
+		{"setuid,other owner", 1234, os.ModeSetuid | os.ModeSetgid | 0755},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+			for _, p := range []string{src, dst} {
+				if err := os.WriteFile(p, []byte("data"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Chown(src, tt.uid, tt.uid); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(src, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(src, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = CopyFileInfo(dst, fi); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Mode() != tt.mode {
+				t.Errorf("mode[%v/%v]", got.Mode(), tt.mode)
+			}
+			if !got.ModTime().Equal(modTime) {
+				t.Errorf("modTime[%v/%v]", got.ModTime(), modTime)
+			}
+			if st := got.Sys().(*syscall.Stat_t); int(st.Uid) != tt.uid || int(st.Gid) != tt.uid {
+				t.Errorf("owner[%v:%v/%v]", st.Uid, st.Gid, tt.uid)
+			}
+		})
+	}
+}
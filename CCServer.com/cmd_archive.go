@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"CCServer.com/cccompress"
+)
+
+// runPack .
+func runPack(name string, args []string) int {
+	var f folderFlags
+	var archivePath string
+	fs := newFlagSet(name, "-a <archive> [flags] <folder>",
+		"Pack every matching file of a folder into one CC archive,each file compressed with\n"+
+			"-m or the first matching -rule,and obfuscated with -k.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&archivePath, "a", "", "Archive file to write")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || len(archivePath) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		return 0, fmt.Errorf("pack[%v].not a folder", f.target)
+	}, func() (int64, error) {
+		return cccompress.PackFolder(f.target, archivePath, f.options())
+	})
+}
+
+// runUnpack .
+func runUnpack(name string, args []string) int {
+	var key, outDir string
+	fs := newFlagSet(name, "-o <folder> [flags] <archive|store>",
+		"Extract every entry of a CC archive,or every path of a content store,keeping the file modes\n"+
+			"and mtimes.")
+	fs.StringVar(&key, "k", "", "Obfuscation key")
+	fs.StringVar(&outDir, "o", "", "Output folder")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 || len(outDir) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, fs.Arg(0), func() (int64, error) {
+		return cccompress.UnpackArchive(fs.Arg(0), outDir, key)
+	}, func() (int64, error) {
+		return cccompress.UnpackStore(fs.Arg(0), outDir, key)
+	})
+}
+
+// archiveEntry is what list reports for an entry.
+type archiveEntry struct {
+	Name       string  `json:"name"`
+	Mode       byte    `json:"mode"`
+	Codec      string  `json:"codec"`
+	Offset     int64   `json:"offset"`
+	Size       int64   `json:"size"`
+	OriginSize int64   `json:"origin"`
+	Ratio      float64 `json:"ratio"`
+	FileMode   string  `json:"file_mode"`
+	ModTime    string  `json:"mod_time"`
+	CRC32      string  `json:"crc32"`
+}
+
+// runList .
+func runList(name string, args []string) int {
+	var key string
+	var bJSON bool
+	fs := newFlagSet(name, "[flags] <archive|store>",
+		"List the entries of a CC archive,or the paths of a content store.")
+	fs.StringVar(&key, "k", "", "Obfuscation key,only needed to read entries")
+	fs.BoolVar(&bJSON, "json", false, "Print JSON instead of text")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if cccompress.IsStore(fs.Arg(0)) {
+		return listStore(fs.Arg(0), key, bJSON)
+	}
+
+	r, err := cccompress.OpenArchive(fs.Arg(0), key)
+	if err != nil {
+		log.Printf("list.err[%v]", err)
+		return exitFailed
+	}
+	defer r.Close()
+
+	entries := make([]*archiveEntry, 0, len(r.Entries()))
+	for _, e := range r.Entries() {
+		ret := &archiveEntry{
+			Name:       e.Name,
+			Mode:       e.Mode,
+			Codec:      codecName(e.Mode),
+			Offset:     e.Offset,
+			Size:       e.Size,
+			OriginSize: e.OriginSize,
+			FileMode:   e.FileMode.String(),
+			ModTime:    e.ModTime.Format(time.RFC3339),
+			CRC32:      fmt.Sprintf("%08x", e.CRC32),
+		}
+		if e.OriginSize > 0 {
+			ret.Ratio = roundRatio(float64(e.Size) / float64(e.OriginSize))
+		}
+		entries = append(entries, ret)
+	}
+
+	if bJSON {
+		printJSON(entries)
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "mode\tcodec\tsize\torigin\tratio\tmtime\tname")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%.2f%%\t%v\t%v\n", e.FileMode, e.Codec, e.Size, e.OriginSize, e.Ratio*100, e.ModTime, e.Name)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// storeEntry is what list reports for a path of a content store.
+type storeEntry struct {
+	Name     string `json:"name"`
+	Hash     string `json:"hash"`
+	Mode     byte   `json:"mode"`
+	Codec    string `json:"codec"`
+	Size     int64  `json:"origin"`
+	FileMode string `json:"file_mode"`
+	ModTime  string `json:"mod_time"`
+}
+
+// listStore .
+func listStore(dir string, key string, bJSON bool) int {
+	s, err := cccompress.OpenStore(dir, key)
+	if err != nil {
+		log.Printf("list.err[%v]", err)
+		return exitFailed
+	}
+
+	var entries []*storeEntry
+	blobs := map[string]bool{}
+	for _, name := range s.Paths() {
+		e := s.Entry(name)
+		blobs[e.Hash] = true
+		entries = append(entries, &storeEntry{
+			Name:     name,
+			Hash:     e.Hash,
+			Mode:     e.Mode,
+			Codec:    codecName(e.Mode),
+			Size:     e.Size,
+			FileMode: e.FileMode.String(),
+			ModTime:  e.ModTime.Format(time.RFC3339),
+		})
+	}
+
+	if bJSON {
+		printJSON(entries)
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "mode\tcodec\torigin\thash\tmtime\tname")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", e.FileMode, e.Codec, e.Size, e.Hash[:12], e.ModTime, e.Name)
+	}
+	w.Flush()
+	fmt.Printf("%v paths,%v blobs\n", len(entries), len(blobs))
+	return exitOK
+}
+
+// codecName .
+func codecName(mode byte) string {
+	if info := cccompress.CodecByMode(mode); info != nil {
+		return info.Name
+	}
+	return fmt.Sprintf("mode[%v]", mode)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// loadSamples reads the target file,or every matching file of the target
+// folder.
+func loadSamples(f *folderFlags) ([][]byte, error) {
+	fi, err := os.Stat(f.target)
+	if err != nil {
+		return nil, err
+	}
+
+	allFile := []string{f.target}
+	if fi.IsDir() {
+		allFile, err = ccutility.GetAllFileByFilter(ccutility.RemoveLastSlash(f.target), f.options().Filter, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	samples := make([][]byte, 0, len(allFile))
+	for _, filePath := range allFile {
+		src, err := ccutility.ReadBinary(filePath)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, src)
+	}
+	return samples, nil
+}
+
+// benchSpecs parses the -c values,every codec/level when there is none.
+func benchSpecs(a []string) ([]cccompress.CodecSpec, error) {
+	if len(a) == 0 {
+		return cccompress.BenchSpecs(), nil
+	}
+	var ret []cccompress.CodecSpec
+	for _, v := range a {
+		for _, s := range ccutility.SplitList(v) {
+			spec, err := cccompress.ParseCodecSpec(s)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, spec)
+		}
+	}
+	return ret, nil
+}
+
+// writeBenchCSV .
+func writeBenchCSV(filePath string, results []*cccompress.CCBenchResult) error {
+	out := os.Stdout
+	if filePath != stdio {
+		f, err := os.Create(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"codec", "mode", "level", "files", "origin", "compressed", "ratio",
+		"compress_mb_s", "decompress_mb_s", "compress_ns", "decompress_ns", "peak_memory"})
+	for _, r := range results {
+		level := ""
+		if r.Spec.HasLevel {
+			level = strconv.Itoa(r.Spec.Level)
+		}
+		w.Write([]string{
+			r.Spec.String(),
+			strconv.Itoa(int(r.Spec.Mode)),
+			level,
+			strconv.Itoa(r.Files),
+			strconv.FormatInt(r.Origin, 10),
+			strconv.FormatInt(r.Compressed, 10),
+			strconv.FormatFloat(r.Ratio(), 'f', 4, 64),
+			strconv.FormatFloat(r.CompressMBs(), 'f', 2, 64),
+			strconv.FormatFloat(r.DecompressMBs(), 'f', 2, 64),
+			strconv.FormatInt(int64(r.Compress), 10),
+			strconv.FormatInt(int64(r.Decompress), 10),
+			strconv.FormatUint(r.PeakMemory, 10),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printBenchTable .
+func printBenchTable(results []*cccompress.CCBenchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "codec\tcompressed\tratio\tcompress MB/s\tdecompress MB/s\tpeak memory\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%v\t%v\t%.2f%%\t%.2f\t%.2f\t%.2f MB\t\n",
+			r.Spec.String(), r.Compressed, r.Ratio()*100, r.CompressMBs(), r.DecompressMBs(), float64(r.PeakMemory)/(1<<20))
+	}
+	w.Flush()
+}
+
+// runBench .
+func runBench(name string, args []string) int {
+	var f folderFlags
+	var codecs stringsFlag
+	var csvPath string
+	fs := newFlagSet(name, "[flags] <file|folder>",
+		"Compress and decompress a sample file or folder with every codec and level,\n"+
+			"and report ratio,throughput and peak memory. The samples are loaded in memory.")
+	f.registerTarget(fs)
+	f.registerFilter(fs)
+	fs.Var(&codecs, "c", "Codec spec to bench e.g. zstd:19,comma separated or repeated,default every codec at its fast/default/best level")
+	fs.StringVar(&csvPath, "csv", "", "Also write the results as CSV to this file,- for stdout")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+	specs, err := benchSpecs(codecs)
+	if err != nil {
+		log.Printf("bench.err[%v]", err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	samples, err := loadSamples(&f)
+	if err != nil {
+		log.Printf("bench[%v].err[%v]", f.target, err)
+		return exitFailed
+	}
+	var total int64
+	for _, src := range samples {
+		total += int64(len(src))
+	}
+	log.Printf("bench[%v].files[%v].size[%v].codecs[%v]", f.target, len(samples), total, len(specs))
+
+	s := time.Now()
+	code := exitOK
+	results := make([]*cccompress.CCBenchResult, 0, len(specs))
+	for _, spec := range specs {
+		r, err := cccompress.BenchCodec(spec, samples)
+		if err != nil {
+			log.Printf("bench.err[%v]", err)
+			code = exitFailed
+			continue
+		}
+		results = append(results, r)
+	}
+
+	if csvPath != stdio {
+		printBenchTable(results)
+	}
+	if len(csvPath) > 0 {
+		if err = writeBenchCSV(csvPath, results); err != nil {
+			log.Printf("bench.csv[%v].err[%v]", csvPath, err)
+			code = exitFailed
+		}
+	}
+
+	cost := time.Now().Unix() - s.Unix()
+	log.Printf("Total[%v].finished!...cost[%v s]", len(results), cost)
+	return code
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"CCServer.com/cccompress"
+)
+
+// runImport .
+func runImport(name string, args []string) int {
+	var f folderFlags
+	var outDir, archivePath string
+	fs := newFlagSet(name, "-o <folder>|-a <archive> [flags] <zip|tar|tar.gz>",
+		"Compress every file of a .zip or .tar(.gz) into a folder(-o) or a CC archive(-a),keeping\n"+
+			"the paths,modes and mtimes. Each file is compressed with -m or the first matching -rule.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&outDir, "o", "", "Output folder")
+	fs.StringVar(&archivePath, "a", "", "Output CC archive")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || (len(outDir) == 0) == (len(archivePath) == 0) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		if len(archivePath) > 0 {
+			return cccompress.ImportBundleToArchive(f.target, archivePath, f.options())
+		}
+		return cccompress.ImportBundle(f.target, outDir, f.options())
+	}, func() (int64, error) {
+		return 0, fmt.Errorf("import[%v].is a folder", f.target)
+	})
+}
+
+// runExport .
+func runExport(name string, args []string) int {
+	var f folderFlags
+	var bundlePath string
+	fs := newFlagSet(name, "-o <zip|tar|tar.gz> [flags] <folder|archive>",
+		"Decode every matching file of a compressed folder,or every entry of a CC archive,into a\n"+
+			".zip/.tar/.tar.gz/.tgz chosen by the extension of -o,keeping the paths,modes and mtimes.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&bundlePath, "o", "", "Output .zip/.tar/.tar.gz/.tgz")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || len(bundlePath) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if _, err := cccompress.BundleFormat(bundlePath); err != nil {
+		log.Printf("export.err[%v]", err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		return cccompress.ExportArchive(f.target, bundlePath, f.key)
+	}, func() (int64, error) {
+		return cccompress.ExportFolder(f.target, bundlePath, f.options())
+	})
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// folderFlags are shared by the commands working on a file or a folder.
+type folderFlags struct {
+	target  string
+	config  string
+	profile string
+
+	spec    cccompress.CodecSpec
+	key     string
+	workers int
+
+	overWrite   bool
+	journal     string
+	skip        bool
+	outDir      string
+	incremental bool
+
+	ext        string
+	include    stringsFlag
+	exclude    stringsFlag
+	minSize    int64
+	maxSize    int64
+	ignoreFile string
+
+	rules rulesFlag // -rule first,then the rules of the config file
+
+	store bool
+
+	manifestVersion string
+	manifestURL     string
+	manifestDir     string
+}
+
+// rulesFlag is a -rule flag "match=spec",it may be given several times
+type rulesFlag []cccompress.CCCodecRule
+
+// String .
+func (p *rulesFlag) String() string {
+	var a []string
+	for i := range *p {
+		a = append(a, (*p)[i].String())
+	}
+	return strings.Join(a, ",")
+}
+
+// Set .
+func (p *rulesFlag) Set(v string) error {
+	rule, err := cccompress.ParseCodecRule(v)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, rule)
+	return nil
+}
+
+// registerTarget .
+func (p *folderFlags) registerTarget(fs *flag.FlagSet) {
+	fs.StringVar(&p.target, "t", "", "Target path,may also be given as the argument")
+	fs.IntVar(&p.workers, "n", 10, "Number of workers when processing folders")
+	fs.StringVar(&p.config, "config", "", "Config file(.yaml/.yml/.toml/.json) giving default values of the flags")
+	fs.StringVar(&p.profile, "profile", "", "Profile of the config file to use")
+}
+
+// registerCodec .
+func (p *folderFlags) registerCodec(fs *flag.FlagSet) {
+	fs.Var(&p.spec, "m", "Compress/Decompress mode as codec[:level],e.g. gzip:best,zstd:19,lz4 or a mode number ("+codecNames()+")")
+	fs.StringVar(&p.key, "k", "", "Obfuscation key")
+	fs.Var(&p.rules, "rule", "Codec of the files matching an extension or a glob,e.g. .json=zstd:19 or \"ui/**/*.png=none\",may be repeated,the first match wins over -m")
+}
+
+// registerJob .
+func (p *folderFlags) registerJob(fs *flag.FlagSet) {
+	fs.BoolVar(&p.overWrite, "w", false, "Overwrite origin files,otherwise rename origin files to .bak")
+	fs.StringVar(&p.journal, "j", "", "Job journal file,finished files are recorded and skipped when re-running a folder job")
+}
+
+// registerOutput .
+func (p *folderFlags) registerOutput(fs *flag.FlagSet) {
+	fs.BoolVar(&p.skip, "s", false, "Skip files already compressed(when compressing) or not compressed(when decompressing)")
+	fs.StringVar(&p.outDir, "o", "", "Output folder,the results keep their paths relative to the target,empty means in place")
+	fs.BoolVar(&p.incremental, "incremental", false, "Only process new or changed files into -o and remove outputs of deleted files")
+}
+
+// registerStore .
+func (p *folderFlags) registerStore(fs *flag.FlagSet) {
+	fs.BoolVar(&p.store, "store", false, "Write a content store into -o: each distinct file once,named by its hash,and a path index")
+}
+
+// registerManifest .
+func (p *folderFlags) registerManifest(fs *flag.FlagSet) {
+	fs.StringVar(&p.manifestVersion, "manifest-version", "", "Write a hot update project.manifest/version.manifest of this version after compressing a folder")
+	fs.StringVar(&p.manifestURL, "manifest-url", "", "Package URL of the hot update manifest,where the clients download the files from")
+	fs.StringVar(&p.manifestDir, "manifest-dir", "", "Folder of the hot update manifests,empty means -o or the target folder")
+}
+
+// registerFilter .
+func (p *folderFlags) registerFilter(fs *flag.FlagSet) {
+	fs.StringVar(&p.ext, "e", "", "Ext,comma separated for several extensions e.g. png,jpg")
+	fs.Var(&p.include, "include", "Only process files matching this glob(doublestar,relative to the target),may be repeated")
+	fs.Var(&p.exclude, "exclude", "Skip files or folders matching this glob(doublestar,relative to the target),may be repeated")
+	fs.Int64Var(&p.minSize, "min-size", 0, "Skip files smaller than this size in bytes,0 means no limit")
+	fs.Int64Var(&p.maxSize, "max-size", 0, "Skip files larger than this size in bytes,0 means no limit")
+	fs.StringVar(&p.ignoreFile, "ignore", ccutility.DefaultIgnoreFile, "Name of the ignore files honoured in folders,empty to disable")
+}
+
+// codecNames lists the registered codecs as "0=none,1=gzip..."
+func codecNames() string {
+	var a []string
+	for _, info := range cccompress.Codecs() {
+		a = append(a, fmt.Sprintf("%v=%v", info.Mode, info.Name))
+	}
+	return strings.Join(a, ",")
+}
+
+// parseTarget takes the target from -t or from the first argument,and fills
+// the flags not given from -config.
+func (p *folderFlags) parseTarget(fs *flag.FlagSet) bool {
+	if len(p.config) > 0 {
+		cfg, err := loadConfig(p.config, p.profile)
+		if err == nil {
+			err = cfg.apply(fs, p)
+		}
+		if err != nil {
+			log.Printf("config[%v].err[%v]", p.config, err)
+			return false
+		}
+	} else if len(p.profile) > 0 {
+		log.Printf("profile[%v] without -config", p.profile)
+		return false
+	}
+
+	if len(p.target) == 0 && fs.NArg() > 0 {
+		p.target = fs.Arg(0)
+	}
+	return len(p.target) > 0
+}
+
+// options .
+func (p *folderFlags) options() *cccompress.CCFolderOptions {
+	opts := cccompress.NewFolderOptions(p.ext, p.key, int(p.spec.Mode), p.overWrite, p.workers)
+	opts.Spec = &p.spec
+	opts.Rules = p.rules
+	opts.Journal = p.journal
+	opts.SkipProcessed = p.skip
+	opts.OutDir = p.outDir
+	opts.Incremental = p.incremental
+	opts.ContentStore = p.store
+	if len(p.manifestVersion) > 0 {
+		opts.HotUpdate = &cccompress.CCHotUpdateOptions{
+			Dir:        p.manifestDir,
+			PackageURL: p.manifestURL,
+			Version:    p.manifestVersion,
+		}
+	}
+	opts.Filter = &ccutility.FileFilter{
+		Exts:       ccutility.SplitList(p.ext),
+		Include:    p.include,
+		Exclude:    p.exclude,
+		MinSize:    p.minSize,
+		MaxSize:    p.maxSize,
+		IgnoreFile: p.ignoreFile,
+	}
+	return opts
+}
+
+// fileSpec is the codec of a file target,the first -rule matching its name
+// or else -m.
+func (p *folderFlags) fileSpec() cccompress.CodecSpec {
+	name := filepath.Base(p.target)
+	for i := range p.rules {
+		if p.rules[i].Match(name) {
+			return p.rules[i].Spec
+		}
+	}
+	return p.spec
+}
+
+// fileOut is where a file target goes: into -o under its name,or in place.
+func (p *folderFlags) fileOut() string {
+	if len(p.outDir) == 0 {
+		return p.target
+	}
+	return filepath.Join(p.outDir, filepath.Base(p.target))
+}
+
+// folderOnly names a flag given that only applies to a folder target,""
+// when there is none or the target is a folder.
+func (p *folderFlags) folderOnly() string {
+	if fi, err := os.Stat(p.target); err != nil || fi.IsDir() {
+		return ""
+	}
+	switch {
+	case p.incremental:
+		return "-incremental"
+	case p.store:
+		return "-store"
+	case len(p.manifestVersion) > 0:
+		return "-manifest-version"
+	}
+	return ""
+}
+
+// stdio as target means stdin to stdout
+const stdio = "-"
+
+// runStream runs fn from stdin to stdout,the log still goes to stderr.
+func runStream(fn func(dst io.Writer, src io.Reader) (int64, error)) int {
+	s := time.Now()
+
+	w := bufio.NewWriter(os.Stdout)
+	dlen, err := fn(w, bufio.NewReader(os.Stdin))
+	if e := w.Flush(); err == nil {
+		err = e
+	}
+
+	cost := time.Now().Unix() - s.Unix()
+	log.Printf("Stream[%v bytes].finished!...cost[%v s].err[%v]", dlen, cost, err)
+	if err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// runFiles runs file on a file target or folder on a folder target,and logs
+// the result the way the tool always did.
+func runFiles(fs *flag.FlagSet, target string, file func() (int64, error), folder func() (int64, error)) int {
+	fi, err := os.Stat(target)
+	if err != nil {
+		log.Printf("Stat[%v].err[%v]", target, err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	s := time.Now()
+
+	var total int64
+	if fi.IsDir() {
+		total, err = folder()
+	} else {
+		total, err = file()
+	}
+
+	cost := time.Now().Unix() - s.Unix()
+	log.Printf("Total[%v].finished!...cost[%v s].err[%v]", total, cost, err)
+	if err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// runCompress .
+func runCompress(name string, args []string) int {
+	var f folderFlags
+	fs := newFlagSet(name, "[flags] <file|folder|->",
+		"Compress a file,or every matching file of a folder,with the given mode.\n"+
+			"With - as target stdin is compressed to stdout.\n"+
+			"With an obfuscation key (-k xxx.yyy) a CC header is written and the data is obfuscated.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerJob(fs)
+	f.registerOutput(fs)
+	f.registerStore(fs)
+	f.registerManifest(fs)
+	f.registerFilter(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if f.target == stdio {
+		return runStream(func(dst io.Writer, src io.Reader) (int64, error) {
+			return cccompress.CompressStream(dst, src, f.key, f.spec)
+		})
+	}
+
+	if flag := f.folderOnly(); len(flag) > 0 {
+		log.Printf("%v[%v].is a file", flag, f.target)
+		fs.Usage()
+		return exitUsage
+	}
+	return runFiles(fs, f.target, func() (int64, error) {
+		if dst := f.fileOut(); dst != f.target {
+			return cccompress.CompressFileSpecTo(f.target, dst, f.key, f.fileSpec())
+		}
+		return cccompress.CompressFileSpec(f.target, f.key, f.fileSpec(), f.overWrite)
+	}, func() (int64, error) {
+		return cccompress.CompressFoldersWithOptions(f.target, f.options())
+	})
+}
+
+// runDecompress .
+func runDecompress(name string, args []string) int {
+	var f folderFlags
+	fs := newFlagSet(name, "[flags] <file|folder|->",
+		"Decompress a file,or every matching file of a folder.\n"+
+			"With - as target stdin is decompressed to stdout.\n"+
+			"With an obfuscation key the mode is read from the CC header,otherwise -m must match the data.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerJob(fs)
+	f.registerOutput(fs)
+	f.registerFilter(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if f.target == stdio {
+		return runStream(func(dst io.Writer, src io.Reader) (int64, error) {
+			return cccompress.DecompressStream(dst, src, f.key, f.spec.Mode)
+		})
+	}
+
+	if flag := f.folderOnly(); len(flag) > 0 {
+		log.Printf("%v[%v].is a file", flag, f.target)
+		fs.Usage()
+		return exitUsage
+	}
+	return runFiles(fs, f.target, func() (int64, error) {
+		if dst := f.fileOut(); dst != f.target {
+			return cccompress.DecompressFileTo(f.target, dst, f.key, int(f.fileSpec().Mode))
+		}
+		return cccompress.DecompressFile(f.target, f.key, int(f.fileSpec().Mode), f.overWrite)
+	}, func() (int64, error) {
+		return cccompress.DecompressFoldersWithOptions(f.target, f.options())
+	})
+}
+
+// runRekey .
+func runRekey(name string, args []string) int {
+	var f folderFlags
+	var newKey string
+	fs := newFlagSet(name, "-k <old key> -new-key <new key> [flags] <file|folder>",
+		"Change the obfuscation key of CC files without recompressing them.")
+	f.registerTarget(fs)
+	fs.StringVar(&f.key, "k", "", "Current obfuscation key")
+	fs.StringVar(&newKey, "new-key", "", "New obfuscation key")
+	f.registerJob(fs)
+	f.registerFilter(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || len(f.key) == 0 || len(newKey) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		return cccompress.RekeyFile(f.target, f.key, newKey, f.overWrite)
+	}, func() (int64, error) {
+		return cccompress.RekeyFolders(f.target, newKey, f.options())
+	})
+}
+
+// runVerify .
+func runVerify(name string, args []string) int {
+	var f folderFlags
+	var source string
+	fs := newFlagSet(name, "[flags] <file|folder>",
+		"Decode a file,or every matching file of a folder,in memory and check its header lengths and checksum.\n"+
+			"With -src every file is also compared to the file at the same relative path of the source tree.\n"+
+			"Nothing is written,the exit status is 1 when a file fails.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&source, "src", "", "Original file,or source folder,to compare with")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		if err := cccompress.VerifyFile(f.target, f.key, int(f.spec.Mode), source); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}, func() (int64, error) {
+		return cccompress.VerifyFolders(f.target, f.options(), source)
+	})
+}
+
+// runRestore .
+func runRestore(name string, args []string) int {
+	var f folderFlags
+	fs := newFlagSet(name, "[flags] <file|folder>",
+		"Put every .bak file of a folder back in place of its file.\n"+
+			"A backup is only restored when it matches its file for the given -k/-m.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		if err := cccompress.RestoreBackup(strings.TrimSuffix(f.target, cccompress.BackupExt), f.key, int(f.spec.Mode)); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}, func() (int64, error) {
+		return cccompress.RestoreBackups(f.target, f.options())
+	})
+}
+
+// runPrune .
+func runPrune(name string, args []string) int {
+	var f folderFlags
+	fs := newFlagSet(name, "[flags] <file|folder>",
+		"Remove every .bak file of a folder whose file still exists.")
+	f.registerTarget(fs)
+	f.registerFilter(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		if err := cccompress.PruneBackup(strings.TrimSuffix(f.target, cccompress.BackupExt)); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}, func() (int64, error) {
+		return cccompress.PruneBackups(f.target, f.options())
+	})
+}
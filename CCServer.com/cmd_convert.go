@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"CCServer.com/ccconvert"
+)
+
+// runConvert converts PNG/JPG/JPEG with custom image quality
+func runConvert(name string, args []string) int {
+	var quality int
+	var src, dst string
+
+	fs := newFlagSet(name, "[-q quality] -src <image> -dst <image>",
+		"Convert PNG/JPG/JPEG images: JPEG is re-encoded with the given quality,\nPNG is reduced to 8 bit colors.")
+	fs.IntVar(&quality, "q", 80, "Convert image with given quality [1,100]")
+	fs.StringVar(&src, "src", "", "source image path")
+	fs.StringVar(&dst, "dst", "", "dest image path")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if quality < 1 || quality > 100 || len(src) == 0 || len(dst) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s := time.Now()
+	err := convertImage(src, dst, quality)
+	cost := time.Now().Unix() - s.Unix()
+	log.Printf("Convert[%v].finished!...cost[%v s].err[%v]", dst, cost, err)
+	if err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// convertImage .
+func convertImage(src string, dst string, quality int) error {
+	var err error
+	ext := ""
+
+	err = ccconvert.Convert(src, dst, nil, func(file *os.File, _ext string) (image.Image, error) {
+		ext = _ext
+		switch ext {
+		case "image/png":
+			return png.Decode(file)
+		case "image/jpeg":
+			return jpeg.Decode(file)
+		default:
+			return nil, nil
+		}
+	}, func(file *os.File, rgba *image.RGBA, options *jpeg.Options) error {
+		switch ext {
+		case "image/png":
+			// 转8bit位深
+			palettedImg := convertTo8Bit(rgba)
+
+			enc := &png.Encoder{
+				CompressionLevel: png.BestCompression,
+			}
+
+			// return enc.Encode(file, palettedImg)
+
+			var buf bytes.Buffer
+			// err = png.Encode(&buf, palettedImg)
+			err = enc.Encode(&buf, palettedImg)
+			if err != nil {
+				return err
+			}
+
+			modifiedData, err := modifyDPI(buf.Bytes(), 72)
+			if err != nil {
+				return err
+			}
+			_, err = file.Write(modifiedData)
+			return err
+
+			// // 转换为8位调色板图像
+			// bounds := rgba.Bounds()
+
+			// // 创建一个包含透明色的256色调色板
+			// plt := make(color.Palette, 0, 256)
+			// plt = append(plt, color.Transparent) // 首先添加透明色
+
+			// // 添加其他颜色，确保总数不超过256
+			// for _, c := range palette.Plan9 {
+			// 	if len(plt) >= 256 {
+			// 		break
+			// 	}
+			// 	plt = append(plt, c)
+			// }
+
+			// paletted := image.NewPaletted(bounds, plt) // Plan9 是一个256色调色板
+			// draw.FloydSteinberg.Draw(paletted, bounds, rgba, image.Point{})
+
+			// enc := &png.Encoder{
+			// 	CompressionLevel: png.BestCompression,
+			// }
+			// return enc.Encode(file, paletted)
+		case "image/jpeg":
+			options.Quality = quality
+			return jpeg.Encode(file, rgba, options)
+		}
+		return nil
+	})
+	if ext == "image/png" {
+		ccconvert.RemoveMetaData(dst)
+	}
+	return err
+}
+
+func convertTo8Bit(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	// 创建一个包含透明色的256色调色板
+	plt := make(color.Palette, 0, 256)
+	plt = append(plt, color.Transparent) // 首先添加透明色
+
+	// 添加其他颜色，确保总数不超过256
+	for _, c := range palette.Plan9 {
+		if len(plt) >= 256 {
+			break
+		}
+		plt = append(plt, c)
+	}
+
+	paletted := image.NewPaletted(bounds, plt)
+	// draw.Draw(paletted, bounds, img, bounds.Min, draw.Src)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, image.Point{})
+	return paletted
+}
+
+func convertTo8Bit2(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+
+	// 统计图像中的颜色分布
+	colorCount := make(map[color.Color]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+			colorCount[c]++
+		}
+	}
+
+	// 将颜色按使用频率排序
+	type colorFrequency struct {
+		color     color.Color
+		frequency int
+	}
+	var sortedColors []colorFrequency
+	for c, freq := range colorCount {
+		sortedColors = append(sortedColors, colorFrequency{c, freq})
+	}
+
+	// 按频率从高到低排序
+	sort.Slice(sortedColors, func(i, j int) bool {
+		return sortedColors[i].frequency > sortedColors[j].frequency
+	})
+
+	// 创建调色板，最多包含 256 种颜色
+	palette := make(color.Palette, 0, 256)
+	palette = append(palette, color.Transparent) // 确保透明色优先
+	for _, cf := range sortedColors {
+		if len(palette) >= 256 {
+			break
+		}
+		palette = append(palette, cf.color)
+	}
+
+	// 创建 Paletted 图像
+	paletted := image.NewPaletted(bounds, palette)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, image.Point{})
+
+	return paletted
+}
+
+func convertTo8Bit3(img image.Image) *image.Paletted {
+	// 使用中值切割算法生成调色板
+	palette := ccconvert.MedianCut(img, 256)
+
+	// 创建 Paletted 图像
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, palette)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			paletted.Set(x, y, img.At(x, y))
+		}
+	}
+	draw.FloydSteinberg.Draw(paletted, bounds, img, image.Point{})
+	return paletted
+}
+
+func convertTo8Bit4(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+
+	// 1. 保留最鲜艳的颜色
+	colorMap := make(map[color.Color]int)
+	maxR, maxG, maxB := 0, 0, 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+			r, g, b, _ := c.RGBA()
+
+			// 记录最高饱和度的颜色
+			if r > uint32(maxR) {
+				maxR = int(r)
+			}
+			if g > uint32(maxG) {
+				maxG = int(g)
+			}
+			if b > uint32(maxB) {
+				maxB = int(b)
+			}
+
+			colorMap[c]++
+		}
+	}
+
+	// 2. 创建包含鲜艳色彩的调色板
+	plt := make(color.Palette, 0, 256)
+	plt = append(plt, color.Transparent)
+
+	// 3. 添加饱和度最高的颜色
+	plt = append(plt, color.RGBA{uint8(maxR >> 8), uint8(maxG >> 8), uint8(maxB >> 8), 255})
+
+	// 4. 使用改进的中值切割填充剩余调色板
+	remainingColors := ccconvert.MedianCut(img, 254) // 预留了2个位置
+	plt = append(plt, remainingColors...)
+
+	// 5. 使用误差扩散保持细节
+	paletted := image.NewPaletted(bounds, plt)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, image.Point{})
+
+	return paletted
+}
+
+func modifyDPI(pngData []byte, dpi int) ([]byte, error) {
+	// 将 DPI 转换为每米像素数 (1 英寸 = 0.0254 米)
+	ppm := uint32(float64(dpi) / 0.0254)
+
+	// 创建 pHYs 块数据
+	physData := make([]byte, 9)
+	binary.BigEndian.PutUint32(physData[0:4], ppm) // 水平像素密度
+	binary.BigEndian.PutUint32(physData[4:8], ppm) // 垂直像素密度
+	physData[8] = 1                                // 单位：每米像素数
+
+	// 计算 CRC 校验值
+	crc := crc32.Checksum(append([]byte("pHYs"), physData...), crc32.MakeTable(crc32.IEEE))
+
+	// 构造 pHYs 块
+	var physChunk bytes.Buffer
+	binary.Write(&physChunk, binary.BigEndian, uint32(len(physData))) // 块长度
+	physChunk.WriteString("pHYs")                                     // 块类型
+	physChunk.Write(physData)                                         // 块数据
+	binary.Write(&physChunk, binary.BigEndian, crc)                   // CRC 校验值
+
+	// 找到第一个 IDAT 块的位置
+	idatIndex := bytes.Index(pngData, []byte("IDAT")) - 4
+	if idatIndex < 0 {
+		return nil, fmt.Errorf("未找到 IDAT 块")
+	}
+
+	// 将 pHYs 块插入到 IDAT 块之前
+	newData := append(pngData[:idatIndex], append(physChunk.Bytes(), pngData[idatIndex:]...)...)
+	return newData, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"CCServer.com/ccembed"
+)
+
+// runEmbed .
+func runEmbed(name string, args []string) int {
+	var f folderFlags
+	opts := &ccembed.CCEmbedOptions{}
+	fs := newFlagSet(name, "-o <file.go> [flags] <folder>",
+		"Pack a folder into a CC archive next to -o,and generate there Go code embedding it with\n"+
+			"go:embed behind an fs.FS that decompresses files when they are opened. Meant for go:generate,\n"+
+			"e.g. //go:generate CCCompress embed -o assets_cc.go -m zstd -k xxx.yyy ./static")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&opts.Output, "o", "", "Go file to generate")
+	fs.StringVar(&opts.Package, "pkg", os.Getenv("GOPACKAGE"), "Package of the generated file,$GOPACKAGE under go generate")
+	fs.StringVar(&opts.Archive, "archive", "", "File name of the archive,default the name of -o with .cca")
+	fs.StringVar(&opts.Func, "func", "FS", "Name of the accessor function")
+	fs.BoolVar(&opts.KeyParam, "key-param", false, "The accessor takes the obfuscation key instead of keeping -k in the generated file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || len(opts.Output) == 0 || len(opts.Package) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return runFiles(fs, f.target, func() (int64, error) {
+		return 0, fmt.Errorf("embed[%v].not a folder", f.target)
+	}, func() (int64, error) {
+		opts.Folder = f.options()
+		return ccembed.Generate(f.target, opts)
+	})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// inspectExt .
+type inspectExt struct {
+	Type  byte   `json:"type"`
+	Name  string `json:"name"`
+	Len   int    `json:"len"`
+	Value string `json:"value"`
+}
+
+// inspectFile is what inspect reports for a file.
+type inspectFile struct {
+	Path       string       `json:"path"`
+	Size       int64        `json:"size"`
+	Header     bool         `json:"header"`
+	Format     string       `json:"format,omitempty"`
+	Version    string       `json:"version,omitempty"`
+	Mode       byte         `json:"mode"`
+	Codec      string       `json:"codec"`
+	Compressed int64        `json:"compressed,omitempty"`
+	Origin     int64        `json:"origin,omitempty"`
+	Ratio      float64      `json:"ratio,omitempty"`
+	Ext        []inspectExt `json:"ext,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// inspectSummary .
+type inspectSummary struct {
+	Files      int            `json:"files"`
+	Headers    int            `json:"headers"`
+	Raw        int            `json:"raw"` // compressed without CC header
+	Plain      int            `json:"plain"`
+	Errors     int            `json:"errors"`
+	Codecs     map[string]int `json:"codecs"`
+	Compressed int64          `json:"compressed"` // of the files with a known origin size
+	Origin     int64          `json:"origin"`
+	Ratio      float64        `json:"ratio,omitempty"`
+}
+
+// inspectPath .
+func inspectPath(filePath string) *inspectFile {
+	ret := &inspectFile{Path: filePath}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+	ret.Size = fi.Size()
+
+	mode, header, ok, err := cccompress.SniffFile(filePath)
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+	ret.Mode = mode
+	ret.Codec = "plain"
+	if ok {
+		ret.Codec = cccompress.CodecByMode(mode).Name
+	}
+	if !header {
+		return ret
+	}
+
+	h, err := cccompress.ReadHeader(filePath)
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+	ret.Header = true
+	ret.Format = fmt.Sprintf("% X", h.Format[:])
+	ret.Version = string(h.Version[:])
+	ret.Compressed = h.CompressedSize()
+	ret.Origin = h.OriginSize()
+	ret.Ratio = roundRatio(h.Ratio())
+	for i := range h.Ext {
+		e := &h.Ext[i]
+		ret.Ext = append(ret.Ext, inspectExt{Type: e.Type, Name: cccompress.ExtName(e.Type), Len: len(e.Data), Value: e.String()})
+	}
+	return ret
+}
+
+// roundRatio keeps 4 decimals of a ratio
+func roundRatio(f float64) float64 {
+	return float64(ccutility.Round(f*10000)) / 10000
+}
+
+// add .
+func (p *inspectSummary) add(f *inspectFile) {
+	p.Files++
+	switch {
+	case len(f.Error) > 0:
+		p.Errors++
+		return
+	case f.Header:
+		p.Headers++
+	case f.Codec != "plain":
+		p.Raw++
+	default:
+		p.Plain++
+	}
+	p.Codecs[f.Codec]++
+	if f.Header && f.Compressed != cccompress.StreamLen && f.Origin != cccompress.StreamLen {
+		p.Compressed += f.Compressed
+		p.Origin += f.Origin
+	}
+}
+
+// printText .
+func (p *inspectFile) printText() {
+	if len(p.Error) > 0 {
+		fmt.Printf("%v: error %v\n", p.Path, p.Error)
+		return
+	}
+	if !p.Header {
+		fmt.Printf("%v: no CC header,%v,%v bytes\n", p.Path, p.Codec, p.Size)
+		return
+	}
+	fmt.Printf("%v:\n", p.Path)
+	fmt.Printf("  format      %v\n", p.Format)
+	fmt.Printf("  version     %v\n", p.Version)
+	fmt.Printf("  mode        %v(%v)\n", p.Mode, p.Codec)
+	fmt.Printf("  compressed  %v\n", sizeText(p.Compressed))
+	fmt.Printf("  origin      %v\n", sizeText(p.Origin))
+	if p.Ratio > 0 {
+		fmt.Printf("  ratio       %.2f%%\n", p.Ratio*100)
+	}
+	for _, e := range p.Ext {
+		fmt.Printf("  ext[%v]      %v(%v bytes) %v\n", e.Type, e.Name, e.Len, e.Value)
+	}
+}
+
+// sizeText .
+func sizeText(n int64) string {
+	if n == cccompress.StreamLen {
+		return "unknown(streamed)"
+	}
+	return fmt.Sprintf("%v", n)
+}
+
+// printText .
+func (p *inspectSummary) printText() {
+	fmt.Printf("\nfiles[%v] header[%v] raw[%v] plain[%v] errors[%v]\n", p.Files, p.Headers, p.Raw, p.Plain, p.Errors)
+	var names []string
+	for name := range p.Codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var a []string
+	for _, name := range names {
+		a = append(a, fmt.Sprintf("%v[%v]", name, p.Codecs[name]))
+	}
+	fmt.Printf("codecs %v\n", strings.Join(a, " "))
+	if p.Ratio > 0 {
+		fmt.Printf("compressed[%v] origin[%v] ratio[%.2f%%]\n", p.Compressed, p.Origin, p.Ratio*100)
+	}
+}
+
+// runInspect .
+func runInspect(name string, args []string) int {
+	var f folderFlags
+	var bJSON bool
+	fs := newFlagSet(name, "[flags] <file|folder>",
+		"Show the CC header of a file,or of every matching file of a folder with a summary.\n"+
+			"Files without a header are reported with the codec recognised by its magic number.")
+	f.registerTarget(fs)
+	f.registerFilter(fs)
+	fs.BoolVar(&bJSON, "json", false, "Print JSON instead of text")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	fi, err := os.Stat(f.target)
+	if err != nil {
+		log.Printf("Stat[%v].err[%v]", f.target, err)
+		fs.Usage()
+		return exitUsage
+	}
+
+	if !fi.IsDir() {
+		ret := inspectPath(f.target)
+		if bJSON {
+			printJSON(ret)
+		} else {
+			ret.printText()
+		}
+		if len(ret.Error) > 0 {
+			return exitFailed
+		}
+		return exitOK
+	}
+
+	var allFile []string
+	allFile, err = ccutility.GetAllFileByFilter(ccutility.RemoveLastSlash(f.target), f.options().Filter, allFile)
+	if err != nil {
+		log.Printf("inspect[%v].err[%v]", f.target, err)
+		return exitFailed
+	}
+
+	summary := &inspectSummary{Codecs: map[string]int{}}
+	files := make([]*inspectFile, 0, len(allFile))
+	for _, filePath := range allFile {
+		ret := inspectPath(filePath)
+		summary.add(ret)
+		files = append(files, ret)
+		if !bJSON {
+			ret.printText()
+		}
+	}
+	if summary.Origin > 0 {
+		summary.Ratio = roundRatio(float64(summary.Compressed) / float64(summary.Origin))
+	}
+
+	if bJSON {
+		printJSON(struct {
+			Files   []*inspectFile  `json:"files"`
+			Summary *inspectSummary `json:"summary"`
+		}{files, summary})
+	} else {
+		summary.printText()
+	}
+	if summary.Errors > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// printJSON .
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
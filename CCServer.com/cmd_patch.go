@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+
+	"CCServer.com/cccompress"
+)
+
+// runDiff .
+func runDiff(name string, args []string) int {
+	var f folderFlags
+	var patchPath string
+	fs := newFlagSet(name, "-o <patch> [flags] <old> <new>",
+		"Write the delta from an old to a new version of a file,as a CC container,or the patch set\n"+
+			"from an old to a new version of a folder,as a CC archive of deltas,new files and removals.\n"+
+			"The patch is compressed with -m or the first matching -rule and obfuscated with -k.")
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&patchPath, "o", "", "Patch file to write")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 || len(patchPath) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	oldPath, newPath := fs.Arg(0), fs.Arg(1)
+
+	return runFiles(fs, newPath, func() (int64, error) {
+		return cccompress.DiffFile(oldPath, newPath, patchPath, f.key, f.spec)
+	}, func() (int64, error) {
+		return cccompress.DiffFolders(oldPath, newPath, patchPath, f.options())
+	})
+}
+
+// runPatch .
+func runPatch(name string, args []string) int {
+	var spec cccompress.CodecSpec
+	var key, outPath string
+	fs := newFlagSet(name, "[flags] <file|folder> <patch>",
+		"Apply a patch made by diff. A folder is patched in place once every change is checked,\n"+
+			"a file is patched in place or into -o.")
+	fs.StringVar(&key, "k", "", "Obfuscation key of the patch")
+	fs.Var(&spec, "m", "Mode of a file patch without CC header ("+codecNames()+")")
+	fs.StringVar(&outPath, "o", "", "Output file when patching a file,empty means in place")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	target, patchPath := fs.Arg(0), fs.Arg(1)
+
+	return runFiles(fs, target, func() (int64, error) {
+		dstPath := outPath
+		if len(dstPath) == 0 {
+			dstPath = target
+		}
+		return cccompress.PatchFile(target, patchPath, dstPath, key, int(spec.Mode))
+	}, func() (int64, error) {
+		if len(outPath) > 0 {
+			log.Printf("patch[%v].-o ignored,folders are patched in place", target)
+		}
+		return cccompress.PatchFolder(target, patchPath, key)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/cchttp"
+)
+
+// specsFlag is a comma separated list of codec specs,e.g. "br:5,gzip"
+type specsFlag []cccompress.CodecSpec
+
+// String .
+func (p *specsFlag) String() string {
+	var a []string
+	for i := range *p {
+		a = append(a, (*p)[i].String())
+	}
+	return strings.Join(a, ",")
+}
+
+// Set .
+func (p *specsFlag) Set(v string) error {
+	var specs specsFlag
+	for _, s := range strings.Split(v, ",") {
+		spec, err := cccompress.ParseCodecSpec(s)
+		if err != nil {
+			return err
+		}
+		if len(cchttp.ContentEncoding(spec.Mode)) == 0 {
+			return fmt.Errorf("specsFlag[%v].no HTTP content coding", s)
+		}
+		specs = append(specs, spec)
+	}
+	*p = specs
+	return nil
+}
+
+// runServe .
+func runServe(name string, args []string) int {
+	var spec cccompress.CodecSpec
+	var compress specsFlag
+	var minSize int
+	var key, addr, index string
+	fs := newFlagSet(name, "[flags] <folder>",
+		"Serve a folder of compressed files over HTTP. Files are sent as stored with Content-Encoding\n"+
+			"when the client accepts their codec(gzip,deflate,br,zstd),otherwise decompressed on the fly.")
+	fs.StringVar(&addr, "addr", ":8080", "Listen address")
+	fs.StringVar(&key, "k", "", "Obfuscation key of the files with a CC header")
+	fs.Var(&spec, "m", "Mode of the files without CC header ("+codecNames()+")")
+	fs.StringVar(&index, "index", cchttp.DefaultIndex, "File served for a folder,empty for none")
+	fs.Var(&compress, "compress", "Also compress the responses sent decoded with these codecs in order of preference,e.g. br,gzip")
+	fs.IntVar(&minSize, "min-size", cchttp.DefaultMinSize, "Smallest response compressed by -compress")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if fi, err := os.Stat(fs.Arg(0)); err != nil || !fi.IsDir() {
+		log.Printf("serve[%v].not a folder.err[%v]", fs.Arg(0), err)
+		return exitUsage
+	}
+
+	server := cchttp.NewFileServer(fs.Arg(0), key, spec.Mode)
+	server.Index = index
+	var h http.Handler = server
+	if len(compress) > 0 {
+		h = cchttp.Compress(h, &cchttp.CCCompressOptions{Specs: compress, MinSize: minSize})
+	}
+	log.Printf("serve[%v].listen[%v]", fs.Arg(0), addr)
+	if err := http.ListenAndServe(addr, h); err != nil {
+		log.Printf("serve.err[%v]", err)
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"CCServer.com/cccompress"
+	"CCServer.com/ccutility"
+)
+
+// runWatch .
+func runWatch(name string, args []string) int {
+	var f folderFlags
+	var wopts cccompress.CCWatchOptions
+	var quality int
+	fs := newFlagSet(name, "-o <folder> [flags] <folder>",
+		"Compress every matching file of a folder into -o,then keep watching it and compress the files\n"+
+			"that are new or modified once they stop changing. Uses file system notifications where\n"+
+			"available,else polling. With -q PNG/JPG/JPEG files are converted before being compressed.\n"+
+			"Stops on Ctrl-C.")
+	f.registerTarget(fs)
+	f.registerCodec(fs)
+	f.registerFilter(fs)
+	fs.StringVar(&f.outDir, "o", "", "Output folder")
+	fs.DurationVar(&wopts.Interval, "interval", 0, "Time between two scans,default 2s when polling and 1m with notifications")
+	fs.DurationVar(&wopts.Debounce, "debounce", cccompress.DefaultWatchDebounce, "How long a file must stay unchanged before it is processed")
+	fs.BoolVar(&wopts.Poll, "poll", false, "Only poll,never use file system notifications")
+	fs.IntVar(&quality, "q", 0, "Convert PNG/JPG/JPEG with this quality [1,100] before compressing them,0 to only compress")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if !f.parseTarget(fs) || len(f.outDir) == 0 || quality < 0 || quality > 100 {
+		fs.Usage()
+		return exitUsage
+	}
+	if fi, err := os.Stat(f.target); err != nil || !fi.IsDir() {
+		log.Printf("watch[%v].not a folder.err[%v]", f.target, err)
+		return exitUsage
+	}
+
+	opts := f.options()
+	if quality > 0 {
+		wopts.Process = func(srcPath string, dstPath string, spec cccompress.CodecSpec) error {
+			return convertAndCompress(srcPath, dstPath, quality, opts.Key, spec)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cccompress.WatchFolders(ctx, f.target, opts, &wopts); err != nil {
+		log.Printf("watch.err[%v]", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// convertAndCompress converts an image into a temporary file then
+// compresses it into dstPath,other files are only compressed. dstPath gets
+// the mtime of srcPath.
+func convertAndCompress(srcPath string, dstPath string, quality int, key string, spec cccompress.CodecSpec) error {
+	switch strings.ToLower(filepath.Ext(srcPath)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		_, err := cccompress.CompressFileSpecTo(srcPath, dstPath, key, spec)
+		return err
+	}
+
+	fi, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("convertAndCompress[%v].Stat.err[%v]", srcPath, err)
+	}
+	tmp, err := os.CreateTemp("", "ccwatch-*"+filepath.Ext(srcPath))
+	if err != nil {
+		return fmt.Errorf("convertAndCompress[%v].CreateTemp.err[%v]", srcPath, err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err = convertImage(srcPath, tmp.Name(), quality); err != nil {
+		return fmt.Errorf("convertAndCompress[%v].err[%v]", srcPath, err)
+	}
+	src, err := ccutility.ReadBinary(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("convertAndCompress[%v].ReadBinary.err[%v]", srcPath, err)
+	}
+	codec, err := spec.Codec()
+	if err != nil {
+		return fmt.Errorf("convertAndCompress[%v].err[%v]", srcPath, err)
+	}
+	// the header keeps the mode and mtime of the source,not of the temp file
+	dst, err := cccompress.CompressWithOptions(key, src, &cccompress.CCOptions{Mode: spec.Mode, Codec: codec, Ext: cccompress.FileInfoExt(fi), Checksum: true})
+	if err != nil {
+		return fmt.Errorf("convertAndCompress[%v].Compress.err[%v]", srcPath, err)
+	}
+	if _, err = ccutility.WriteBinary(dstPath, dst); err != nil {
+		return err
+	}
+	return ccutility.CopyFileInfo(dstPath, fi)
+}
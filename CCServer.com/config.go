@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"CCServer.com/cccompress"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ccConfigRule picks the codec of the files ending with Ext and/or matching
+// the glob Pattern.
+type ccConfigRule struct {
+	Ext     string `json:"ext" yaml:"ext" toml:"ext"`
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Mode    string `json:"mode" yaml:"mode" toml:"mode"`
+}
+
+// ccConfig is the content of a -config file,YAML,TOML or JSON by its
+// extension. Every field is optional,a flag given on the command line wins
+// over it. A profile is a ccConfig whose set fields override the top level.
+type ccConfig struct {
+	Mode    string `json:"mode" yaml:"mode" toml:"mode"`
+	Key     string `json:"key" yaml:"key" toml:"key"`
+	KeyFile string `json:"key_file" yaml:"key_file" toml:"key_file"` // relative to the config file
+	Workers int    `json:"workers" yaml:"workers" toml:"workers"`
+
+	OverWrite   *bool  `json:"overwrite" yaml:"overwrite" toml:"overwrite"`
+	Journal     string `json:"journal" yaml:"journal" toml:"journal"`
+	Skip        *bool  `json:"skip" yaml:"skip" toml:"skip"`
+	Out         string `json:"out" yaml:"out" toml:"out"`
+	Incremental *bool  `json:"incremental" yaml:"incremental" toml:"incremental"`
+	Store       *bool  `json:"store" yaml:"store" toml:"store"`
+
+	Ext     string   `json:"ext" yaml:"ext" toml:"ext"`
+	Include []string `json:"include" yaml:"include" toml:"include"`
+	Exclude []string `json:"exclude" yaml:"exclude" toml:"exclude"`
+	MinSize int64    `json:"min_size" yaml:"min_size" toml:"min_size"`
+	MaxSize int64    `json:"max_size" yaml:"max_size" toml:"max_size"`
+	Ignore  *string  `json:"ignore" yaml:"ignore" toml:"ignore"`
+
+	Rules []ccConfigRule `json:"rules" yaml:"rules" toml:"rules"`
+
+	ManifestVersion string `json:"manifest_version" yaml:"manifest_version" toml:"manifest_version"`
+	ManifestURL     string `json:"manifest_url" yaml:"manifest_url" toml:"manifest_url"`
+	ManifestDir     string `json:"manifest_dir" yaml:"manifest_dir" toml:"manifest_dir"` // relative to the config file
+
+	Profiles map[string]*ccConfig `json:"profiles" yaml:"profiles" toml:"profiles"`
+}
+
+// loadConfig reads filePath and applies profile on top of it.
+func loadConfig(filePath string, profile string) (*ccConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig[%v].err[%v]", filePath, err)
+	}
+
+	cfg := &ccConfig{}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), cfg)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", md.Undecoded())
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		err = fmt.Errorf("unknown format,use .yaml/.yml/.toml/.json")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig[%v].err[%v]", filePath, err)
+	}
+
+	if len(profile) > 0 {
+		p, ok := cfg.Profiles[profile]
+		if !ok || p == nil {
+			return nil, fmt.Errorf("loadConfig[%v].profile[%v].not found", filePath, profile)
+		}
+		cfg.merge(p)
+	}
+
+	// paths in the file are relative to it
+	dir := filepath.Dir(filePath)
+	cfg.KeyFile = configPath(dir, cfg.KeyFile)
+	cfg.Journal = configPath(dir, cfg.Journal)
+	cfg.Out = configPath(dir, cfg.Out)
+	cfg.ManifestDir = configPath(dir, cfg.ManifestDir)
+	return cfg, nil
+}
+
+// configPath .
+func configPath(dir string, p string) string {
+	if len(p) == 0 || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// merge overrides p with the fields set in o.
+func (p *ccConfig) merge(o *ccConfig) {
+	if len(o.Mode) > 0 {
+		p.Mode = o.Mode
+	}
+	if len(o.Key) > 0 || len(o.KeyFile) > 0 {
+		p.Key, p.KeyFile = o.Key, o.KeyFile
+	}
+	if o.Workers > 0 {
+		p.Workers = o.Workers
+	}
+	if o.OverWrite != nil {
+		p.OverWrite = o.OverWrite
+	}
+	if len(o.Journal) > 0 {
+		p.Journal = o.Journal
+	}
+	if o.Skip != nil {
+		p.Skip = o.Skip
+	}
+	if len(o.Out) > 0 {
+		p.Out = o.Out
+	}
+	if o.Incremental != nil {
+		p.Incremental = o.Incremental
+	}
+	if o.Store != nil {
+		p.Store = o.Store
+	}
+	if len(o.Ext) > 0 {
+		p.Ext = o.Ext
+	}
+	if o.Include != nil {
+		p.Include = o.Include
+	}
+	if o.Exclude != nil {
+		p.Exclude = o.Exclude
+	}
+	if o.MinSize > 0 {
+		p.MinSize = o.MinSize
+	}
+	if o.MaxSize > 0 {
+		p.MaxSize = o.MaxSize
+	}
+	if o.Ignore != nil {
+		p.Ignore = o.Ignore
+	}
+	if o.Rules != nil {
+		p.Rules = o.Rules
+	}
+	if len(o.ManifestVersion) > 0 {
+		p.ManifestVersion = o.ManifestVersion
+	}
+	if len(o.ManifestURL) > 0 {
+		p.ManifestURL = o.ManifestURL
+	}
+	if len(o.ManifestDir) > 0 {
+		p.ManifestDir = o.ManifestDir
+	}
+}
+
+// readKeyFile returns the first line of filePath.
+func readKeyFile(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("readKeyFile[%v].err[%v]", filePath, err)
+	}
+	key, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(key), nil
+}
+
+// apply fills the flags of f not given on the command line.
+func (p *ccConfig) apply(fs *flag.FlagSet, f *folderFlags) error {
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+	// only the flags the command has
+	use := func(name string) bool {
+		return !set[name] && fs.Lookup(name) != nil
+	}
+
+	if use("m") && len(p.Mode) > 0 {
+		if err := f.spec.Set(p.Mode); err != nil {
+			return err
+		}
+	}
+	if use("k") {
+		if len(p.KeyFile) > 0 {
+			key, err := readKeyFile(p.KeyFile)
+			if err != nil {
+				return err
+			}
+			f.key = key
+		} else if len(p.Key) > 0 {
+			f.key = p.Key
+		}
+	}
+	if use("n") && p.Workers > 0 {
+		f.workers = p.Workers
+	}
+	if use("w") && p.OverWrite != nil {
+		f.overWrite = *p.OverWrite
+	}
+	if use("j") && len(p.Journal) > 0 {
+		f.journal = p.Journal
+	}
+	if use("s") && p.Skip != nil {
+		f.skip = *p.Skip
+	}
+	if use("o") && len(p.Out) > 0 {
+		f.outDir = p.Out
+	}
+	if use("incremental") && p.Incremental != nil {
+		f.incremental = *p.Incremental
+	}
+	if use("store") && p.Store != nil {
+		f.store = *p.Store
+	}
+	if use("e") && len(p.Ext) > 0 {
+		f.ext = p.Ext
+	}
+	if use("include") && p.Include != nil {
+		f.include = p.Include
+	}
+	if use("exclude") && p.Exclude != nil {
+		f.exclude = p.Exclude
+	}
+	if use("min-size") && p.MinSize > 0 {
+		f.minSize = p.MinSize
+	}
+	if use("max-size") && p.MaxSize > 0 {
+		f.maxSize = p.MaxSize
+	}
+	if use("ignore") && p.Ignore != nil {
+		f.ignoreFile = *p.Ignore
+	}
+	if use("manifest-version") && len(p.ManifestVersion) > 0 {
+		f.manifestVersion = p.ManifestVersion
+	}
+	if use("manifest-url") && len(p.ManifestURL) > 0 {
+		f.manifestURL = p.ManifestURL
+	}
+	if use("manifest-dir") && len(p.ManifestDir) > 0 {
+		f.manifestDir = p.ManifestDir
+	}
+	// rules add to -m and -rule rather than being overridden by them
+	if fs.Lookup("m") != nil {
+		for _, r := range p.Rules {
+			spec, err := cccompress.ParseCodecSpec(r.Mode)
+			if err != nil {
+				return fmt.Errorf("rule[%v%v].err[%v]", r.Ext, r.Pattern, err)
+			}
+			rule := cccompress.CCCodecRule{Ext: r.Ext, Pattern: r.Pattern, Spec: spec}
+			if err = rule.Validate(); err != nil {
+				return err
+			}
+			f.rules = append(f.rules, rule)
+		}
+	}
+	return nil
+}
@@ -5,12 +5,19 @@ go 1.24
 toolchain go1.24.2
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/andybalholm/brotli v1.2.0
+	github.com/bmatcuk/doublestar/v4 v4.9.1
 	github.com/disintegration/imaging v1.6.2
 	github.com/dsnet/compress v0.0.1
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/klauspost/compress v1.18.0
 	github.com/pierrec/lz4 v2.6.1+incompatible
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/frankban/quicktest v1.13.1 // indirect
 	golang.org/x/image v0.25.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )